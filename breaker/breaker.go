@@ -0,0 +1,258 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"test/webook/pkg/logger"
+	"time"
+)
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Breaker 熔断器。
+// 关闭状态下统计滑动窗口内的错误率和慢调用比例，超过阈值就打开；
+// 打开状态下拒绝所有请求，openTimeout 之后进入半开状态；
+// 半开状态下放行 halfOpenProbes 个请求试探，全部成功就关闭，有一个失败就重新打开；
+// 试探的请求放完之后 probeTimeout 内还没有全部反馈结果，也当作失败重新打开
+type Breaker struct {
+	name string
+	l    logger.Logger
+
+	bucketDur   time.Duration
+	minRequests int64
+	errRate     float64
+	// slowCall 为 0 表示不统计慢调用
+	slowCall       time.Duration
+	slowRate       float64
+	openTimeout    time.Duration
+	halfOpenProbes int64
+	probeTimeout   time.Duration
+
+	lock  sync.Mutex
+	state string
+	// 每次状态变化加一，旧状态下放行的请求结束之后不再统计
+	generation int64
+	openedAt   time.Time
+	// 最后一个试探请求放行的时间
+	probedAt time.Time
+	buckets  []bucket
+	probes   int64
+	probeOK  int64
+	now      func() time.Time
+}
+
+type bucket struct {
+	idx    int64
+	total  int64
+	failed int64
+	slow   int64
+}
+
+func NewBreaker(name string, l logger.Logger) *Breaker {
+	b := &Breaker{
+		name:           name,
+		l:              l,
+		minRequests:    20,
+		errRate:        0.5,
+		slowRate:       0.5,
+		openTimeout:    time.Second * 5,
+		halfOpenProbes: 5,
+		probeTimeout:   time.Second * 5,
+		state:          StateClosed,
+		now:            time.Now,
+	}
+	return b.SetWindow(time.Second*10, 10)
+}
+
+// SetWindow 统计窗口，切成 bucketCnt 个桶滑动。bucketCnt 必须是正数，每个桶至少 1ns
+func (b *Breaker) SetWindow(window time.Duration, bucketCnt int) *Breaker {
+	if bucketCnt <= 0 || window < time.Duration(bucketCnt) {
+		panic(fmt.Sprintf("breaker: window %s can not be split into %d buckets", window, bucketCnt))
+	}
+	b.bucketDur = window / time.Duration(bucketCnt)
+	b.buckets = make([]bucket, bucketCnt)
+	return b
+}
+
+// SetMinRequests 窗口内请求数达到 val 之后才会判断是否熔断
+func (b *Breaker) SetMinRequests(val int64) *Breaker {
+	b.minRequests = val
+	return b
+}
+
+// SetErrorRate 错误率达到 val 就熔断
+func (b *Breaker) SetErrorRate(val float64) *Breaker {
+	b.errRate = val
+	return b
+}
+
+// SetSlowCall 耗时超过 duration 的是慢调用，慢调用比例达到 rate 就熔断
+func (b *Breaker) SetSlowCall(duration time.Duration, rate float64) *Breaker {
+	b.slowCall = duration
+	b.slowRate = rate
+	return b
+}
+
+// SetOpenTimeout 打开之后多久进入半开状态
+func (b *Breaker) SetOpenTimeout(val time.Duration) *Breaker {
+	b.openTimeout = val
+	return b
+}
+
+// SetHalfOpenProbes 半开状态下放行的请求数
+func (b *Breaker) SetHalfOpenProbes(val int64) *Breaker {
+	b.halfOpenProbes = val
+	return b
+}
+
+// SetProbeTimeout 试探请求放完之后，超过 val 还没有全部反馈结果就重新打开，
+// 避免试探请求丢了 done 之后一直卡在半开状态
+func (b *Breaker) SetProbeTimeout(val time.Duration) *Breaker {
+	b.probeTimeout = val
+	return b
+}
+
+func (b *Breaker) State() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.checkTimeout(b.now())
+	return b.state
+}
+
+// Allow 熔断的时候返回 ErrOpen。
+// 放行的请求结束之后要调用 done 反馈结果，err 不为 nil 表示失败
+func (b *Breaker) Allow() (done func(err error), err error) {
+	start := b.now()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.checkTimeout(start)
+	switch b.state {
+	case StateOpen:
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.probes >= b.halfOpenProbes {
+			return nil, ErrOpen
+		}
+		b.probes++
+		b.probedAt = start
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			b.done(generation, start, err)
+		})
+	}, nil
+}
+
+// Do 在熔断器的保护下执行 fn，fn panic 了也算失败，之后继续 panic
+func (b *Breaker) Do(fn func() error) (err error) {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			done(fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+		done(err)
+	}()
+	return fn()
+}
+
+func (b *Breaker) done(generation int64, start time.Time, err error) {
+	now := b.now()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if generation != b.generation {
+		return
+	}
+
+	slow := b.slowCall > 0 && now.Sub(start) >= b.slowCall
+	switch b.state {
+	case StateHalfOpen:
+		if err != nil || slow {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.probeOK++
+		if b.probeOK >= b.halfOpenProbes {
+			b.setState(StateClosed, now)
+		}
+	case StateClosed:
+		idx := now.UnixNano() / int64(b.bucketDur)
+		bk := &b.buckets[idx%int64(len(b.buckets))]
+		if bk.idx != idx {
+			*bk = bucket{idx: idx}
+		}
+		bk.total++
+		if err != nil {
+			bk.failed++
+		}
+		if slow {
+			bk.slow++
+		}
+		b.checkThreshold(idx, now)
+	}
+}
+
+func (b *Breaker) checkThreshold(cur int64, now time.Time) {
+	var total, failed, slow int64
+	for _, bk := range b.buckets {
+		if cur-bk.idx >= int64(len(b.buckets)) {
+			continue
+		}
+		total += bk.total
+		failed += bk.failed
+		slow += bk.slow
+	}
+	if total < b.minRequests || total == 0 {
+		return
+	}
+	if float64(failed)/float64(total) >= b.errRate ||
+		(b.slowCall > 0 && float64(slow)/float64(total) >= b.slowRate) {
+		b.setState(StateOpen, now)
+	}
+}
+
+func (b *Breaker) checkTimeout(now time.Time) {
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) >= b.openTimeout {
+			b.setState(StateHalfOpen, now)
+		}
+	case StateHalfOpen:
+		if b.probes >= b.halfOpenProbes && now.Sub(b.probedAt) >= b.probeTimeout {
+			b.setState(StateOpen, now)
+		}
+	}
+}
+
+func (b *Breaker) setState(state string, now time.Time) {
+	b.l.Warn("熔断器状态变化",
+		logger.Any("name", b.name),
+		logger.Any("from", b.state),
+		logger.Any("to", state))
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.probeOK = 0
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		for i := range b.buckets {
+			b.buckets[i] = bucket{}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package breaker
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"test/webook/pkg/logger"
+	"testing"
+	"time"
+)
+
+var errMock = errors.New("mock error")
+
+func newTestBreaker() (*Breaker, *time.Time) {
+	now := time.UnixMilli(1_000_000)
+	b := NewBreaker("test", logger.NewNoLogger()).
+		SetMinRequests(4).
+		SetErrorRate(0.5).
+		SetOpenTimeout(time.Second).
+		SetHalfOpenProbes(2).
+		SetProbeTimeout(time.Second)
+	b.now = func() time.Time {
+		return now
+	}
+	return b, &now
+}
+
+func TestBreaker_Open(t *testing.T) {
+	b, now := newTestBreaker()
+
+	// 请求数不够，全部失败也不熔断
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, errMock, b.Do(func() error { return errMock }))
+	}
+	assert.Equal(t, StateClosed, b.State())
+
+	// 滑出窗口的请求不再统计
+	*now = now.Add(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.Do(func() error { return nil }))
+	}
+	assert.Equal(t, errMock, b.Do(func() error { return errMock }))
+	assert.Equal(t, StateClosed, b.State())
+
+	assert.Equal(t, errMock, b.Do(func() error { return errMock }))
+	assert.Equal(t, errMock, b.Do(func() error { return errMock }))
+	assert.Equal(t, StateOpen, b.State())
+
+	called := false
+	err := b.Do(func() error {
+		called = true
+		return nil
+	})
+	assert.Equal(t, ErrOpen, err)
+	assert.False(t, called)
+}
+
+func TestBreaker_SlowCall(t *testing.T) {
+	b, now := newTestBreaker()
+	b.SetSlowCall(100*time.Millisecond, 0.5)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, b.Do(func() error {
+			*now = now.Add(100 * time.Millisecond)
+			return nil
+		}))
+	}
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	testCases := []struct {
+		name    string
+		results []error
+
+		wantState string
+	}{
+		{
+			name:      "试探全部成功",
+			results:   []error{nil, nil},
+			wantState: StateClosed,
+		},
+		{
+			name:      "试探失败",
+			results:   []error{nil, errMock},
+			wantState: StateOpen,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, now := newTestBreaker()
+			open(t, b)
+
+			*now = now.Add(999 * time.Millisecond)
+			assert.Equal(t, StateOpen, b.State())
+			*now = now.Add(time.Millisecond)
+			assert.Equal(t, StateHalfOpen, b.State())
+
+			dones := make([]func(err error), 0, len(tc.results))
+			for range tc.results {
+				done, err := b.Allow()
+				require.NoError(t, err)
+				dones = append(dones, done)
+			}
+			// 超过试探的数量
+			_, err := b.Allow()
+			assert.Equal(t, ErrOpen, err)
+
+			for i, done := range dones {
+				done(tc.results[i])
+			}
+			assert.Equal(t, tc.wantState, b.State())
+		})
+	}
+}
+
+func TestBreaker_HalfOpenPanic(t *testing.T) {
+	b, now := newTestBreaker()
+	open(t, b)
+	*now = now.Add(time.Second)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = b.Do(func() error {
+			panic("boom")
+		})
+	})
+	// panic 的试探请求算失败
+	assert.Equal(t, StateOpen, b.State())
+
+	*now = now.Add(time.Second)
+	require.NoError(t, b.Do(func() error { return nil }))
+	require.NoError(t, b.Do(func() error { return nil }))
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_ProbeTimeout(t *testing.T) {
+	b, now := newTestBreaker()
+	open(t, b)
+	*now = now.Add(time.Second)
+
+	// 试探请求都放出去了，但是一直没有反馈
+	for i := 0; i < 2; i++ {
+		_, err := b.Allow()
+		require.NoError(t, err)
+	}
+	*now = now.Add(999 * time.Millisecond)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	*now = now.Add(time.Millisecond)
+	assert.Equal(t, StateOpen, b.State())
+
+	*now = now.Add(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+	_, err := b.Allow()
+	require.NoError(t, err)
+}
+
+func TestBreaker_StaleDone(t *testing.T) {
+	b, _ := newTestBreaker()
+	done, err := b.Allow()
+	require.NoError(t, err)
+	open(t, b)
+
+	// 关闭状态下放行的请求在打开之后才结束，不影响之后的状态
+	done(nil)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func open(t *testing.T, b *Breaker) {
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, errMock, b.Do(func() error { return errMock }))
+	}
+	require.Equal(t, StateOpen, b.State())
+}
+
+func TestBreaker_SetWindow(t *testing.T) {
+	testCases := []struct {
+		name      string
+		window    time.Duration
+		bucketCnt int
+	}{
+		{
+			name:   "没有桶",
+			window: time.Second,
+		},
+		{
+			name:      "桶的数量是负数",
+			window:    time.Second,
+			bucketCnt: -1,
+		},
+		{
+			name:      "窗口切不出这么多桶",
+			window:    5 * time.Nanosecond,
+			bucketCnt: 10,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Panics(t, func() {
+				NewBreaker("test", logger.NewNoLogger()).SetWindow(tc.window, tc.bucketCnt)
+			})
+		})
+	}
+}
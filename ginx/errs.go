@@ -0,0 +1,127 @@
+package ginx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Error 业务错误。Msg 是返回给用户的提示，Cause 是内部原因，只用来记录日志，不会返回给前端
+type Error struct {
+	Code int
+	Msg  string
+	// HTTP 状态码，为 0 的时候使用 200
+	Status int
+	Cause  error
+}
+
+func (e *Error) Error() string {
+	if e.Cause == nil {
+		return fmt.Sprintf("code: %d, msg: %s", e.Code, e.Msg)
+	}
+	return fmt.Sprintf("code: %d, msg: %s, cause: %v", e.Code, e.Msg, e.Cause)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Is 错误码相同就认为是同一个错误，这样 Wrap 之后依旧可以用 errors.Is 判断
+func (e *Error) Is(target error) bool {
+	var t *Error
+	return errors.As(target, &t) && t.Code == e.Code
+}
+
+// Wrap 返回带上内部原因的副本，不会修改 e 本身
+func (e *Error) Wrap(cause error) *Error {
+	res := *e
+	res.Cause = cause
+	return &res
+}
+
+// WithStatus 返回指定 HTTP 状态码的副本
+func (e *Error) WithStatus(status int) *Error {
+	res := *e
+	res.Status = status
+	return &res
+}
+
+func (e *Error) Result() Result {
+	return Result{Code: e.Code, Msg: e.Msg}
+}
+
+func (e *Error) HTTPStatus() int {
+	if e.Status == 0 {
+		return http.StatusOK
+	}
+	return e.Status
+}
+
+// ToResult 把错误转换成 HTTP 状态码和 Result，不是 *Error 的错误一律按照系统错误处理，不暴露内部原因
+func ToResult(err error) (int, Result) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = ErrInternal
+	}
+	return e.HTTPStatus(), e.Result()
+}
+
+// Module 每个模块的错误码占用一段区间 [Min, Max]，避免不同模块的错误码冲突
+type Module struct {
+	Name string
+	Min  int
+	Max  int
+}
+
+var (
+	moduleLock sync.Mutex
+	modules    []*Module
+
+	errInvalidCodeRange = errors.New("invalid code range")
+	errCodeRangeOverlap = errors.New("code range overlaps with another module")
+)
+
+// RegisterModule 注册模块的错误码区间，和已有的区间重叠的时候返回错误
+func RegisterModule(name string, min, max int) (*Module, error) {
+	if min > max {
+		return nil, errInvalidCodeRange
+	}
+	moduleLock.Lock()
+	defer moduleLock.Unlock()
+	for _, m := range modules {
+		if min <= m.Max && m.Min <= max {
+			return nil, fmt.Errorf("%w: %s [%d, %d]", errCodeRangeOverlap, m.Name, m.Min, m.Max)
+		}
+	}
+	m := &Module{Name: name, Min: min, Max: max}
+	modules = append(modules, m)
+	return m, nil
+}
+
+// MustRegisterModule 和 RegisterModule 一样，出错的时候 panic，适合在包初始化的时候使用
+func MustRegisterModule(name string, min, max int) *Module {
+	m, err := RegisterModule(name, min, max)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// NewError 错误码不在模块的区间内会 panic，错误一般定义成包变量，启动的时候就能发现问题
+func (m *Module) NewError(code int, msg string) *Error {
+	if code < m.Min || code > m.Max {
+		panic(fmt.Sprintf("code %d out of range of module %s [%d, %d]", code, m.Name, m.Min, m.Max))
+	}
+	return &Error{Code: code, Msg: msg}
+}
+
+// 0 表示成功，1 - 999 留给通用错误
+var (
+	systemModule = MustRegisterModule("system", 1, 999)
+
+	ErrInternal     = systemModule.NewError(1, "系统错误").WithStatus(http.StatusInternalServerError)
+	ErrInvalidParam = systemModule.NewError(2, "参数错误").WithStatus(http.StatusBadRequest)
+	ErrUnauthorized = systemModule.NewError(3, "未登录").WithStatus(http.StatusUnauthorized)
+	ErrTimeout      = systemModule.NewError(4, "请求超时").WithStatus(http.StatusGatewayTimeout)
+)
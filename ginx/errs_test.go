@@ -0,0 +1,137 @@
+package ginx
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+// resetModules 模块注册表是全局的，测试结束之后恢复原样
+func resetModules(t *testing.T) {
+	moduleLock.Lock()
+	saved := append([]*Module(nil), modules...)
+	moduleLock.Unlock()
+	t.Cleanup(func() {
+		moduleLock.Lock()
+		modules = saved
+		moduleLock.Unlock()
+	})
+}
+
+func TestRegisterModule(t *testing.T) {
+	resetModules(t)
+	_, err := RegisterModule("user", 10000, 19999)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		min  int
+		max  int
+
+		wantErr error
+	}{
+		{
+			name:    "区间不合法",
+			min:     30000,
+			max:     20000,
+			wantErr: errInvalidCodeRange,
+		},
+		{
+			name:    "和已有的模块重叠",
+			min:     19999,
+			max:     29999,
+			wantErr: errCodeRangeOverlap,
+		},
+		{
+			name:    "和系统错误码重叠",
+			min:     0,
+			max:     1,
+			wantErr: errCodeRangeOverlap,
+		},
+		{
+			name: "紧挨着已有的模块",
+			min:  20000,
+			max:  29999,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := RegisterModule(tc.name, tc.min, tc.max)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err == nil {
+				assert.Equal(t, &Module{Name: tc.name, Min: tc.min, Max: tc.max}, m)
+			}
+		})
+	}
+
+	assert.Panics(t, func() {
+		MustRegisterModule("user2", 15000, 15999)
+	})
+}
+
+func TestModule_NewError(t *testing.T) {
+	resetModules(t)
+	m := MustRegisterModule("user", 10000, 19999)
+	assert.Equal(t, &Error{Code: 10001, Msg: "用户不存在"}, m.NewError(10001, "用户不存在"))
+	for _, code := range []int{9999, 20000} {
+		assert.Panics(t, func() {
+			m.NewError(code, "越界")
+		}, code)
+	}
+}
+
+func TestError(t *testing.T) {
+	errUserNotFound := &Error{Code: 10001, Msg: "用户不存在"}
+	cause := errors.New("record not found")
+
+	wrapped := errUserNotFound.Wrap(cause)
+	// 不修改原来的错误
+	assert.Nil(t, errUserNotFound.Cause)
+	assert.ErrorIs(t, wrapped, errUserNotFound)
+	assert.ErrorIs(t, wrapped, cause)
+	assert.ErrorIs(t, fmt.Errorf("查询用户: %w", wrapped), errUserNotFound)
+	assert.NotErrorIs(t, wrapped, &Error{Code: 10002})
+	assert.Equal(t, "code: 10001, msg: 用户不存在, cause: record not found", wrapped.Error())
+
+	withStatus := wrapped.WithStatus(http.StatusNotFound)
+	assert.Equal(t, http.StatusOK, wrapped.HTTPStatus())
+	assert.Equal(t, http.StatusNotFound, withStatus.HTTPStatus())
+	assert.ErrorIs(t, withStatus, errUserNotFound)
+
+	testCases := []struct {
+		name string
+		err  error
+
+		wantStatus int
+		wantResult Result
+	}{
+		{
+			name:       "业务错误",
+			err:        withStatus,
+			wantStatus: http.StatusNotFound,
+			wantResult: Result{Code: 10001, Msg: "用户不存在"},
+		},
+		{
+			name:       "包装过的业务错误",
+			err:        fmt.Errorf("查询用户: %w", wrapped),
+			wantStatus: http.StatusOK,
+			wantResult: Result{Code: 10001, Msg: "用户不存在"},
+		},
+		{
+			name:       "其它错误",
+			err:        cause,
+			wantStatus: http.StatusInternalServerError,
+			wantResult: Result{Code: 1, Msg: "系统错误"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, res := ToResult(tc.err)
+			assert.Equal(t, tc.wantStatus, status)
+			assert.Equal(t, tc.wantResult, res)
+		})
+	}
+}
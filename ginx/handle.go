@@ -1,7 +1,25 @@
 package ginx
 
+import "github.com/gin-gonic/gin"
+
+// ResultCodeKey 响应的 Result.Code 放在 gin.Context 的这个 key 下面，统计错误码的中间件会用到
+const ResultCodeKey = "result_code"
+
 type Result struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
 	Data any    `json:"data"`
 }
+
+// JSON 响应 Result 并记录错误码
+func JSON(ctx *gin.Context, status int, res Result) {
+	ctx.Set(ResultCodeKey, res.Code)
+	ctx.JSON(status, res)
+}
+
+// AbortWithError 按照 ToResult 的规则响应错误，并且不再执行后面的 handler
+func AbortWithError(ctx *gin.Context, err error) {
+	status, res := ToResult(err)
+	ctx.Set(ResultCodeKey, res.Code)
+	ctx.AbortWithStatusJSON(status, res)
+}
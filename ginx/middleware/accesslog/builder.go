@@ -0,0 +1,140 @@
+package accesslog
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strings"
+	"test/webook/pkg/logger"
+	"time"
+)
+
+type MiddlewareBuilder struct {
+	l             logger.Logger
+	allowReqBody  bool
+	allowRespBody bool
+	// 请求体和响应体最多记录多少字节
+	maxBodySize int64
+	// 只有这些 Content-Type 的请求体和响应体才会记录，前缀匹配
+	contentTypes []string
+}
+
+func NewMiddlewareBuilder(l logger.Logger) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		l:            l,
+		maxBodySize:  1024,
+		contentTypes: []string{"application/json", "application/x-www-form-urlencoded", "text/"},
+	}
+}
+
+// AllowReqBody 记录请求体
+func (m *MiddlewareBuilder) AllowReqBody() *MiddlewareBuilder {
+	m.allowReqBody = true
+	return m
+}
+
+// AllowRespBody 记录响应体
+func (m *MiddlewareBuilder) AllowRespBody() *MiddlewareBuilder {
+	m.allowRespBody = true
+	return m
+}
+
+func (m *MiddlewareBuilder) MaxBodySize(size int64) *MiddlewareBuilder {
+	m.maxBodySize = size
+	return m
+}
+
+func (m *MiddlewareBuilder) ContentTypes(types ...string) *MiddlewareBuilder {
+	m.contentTypes = types
+	return m
+}
+
+func (m *MiddlewareBuilder) Builder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		fields := make([]logger.Field, 0, 8)
+
+		if m.allowReqBody && ctx.Request.Body != nil && m.allowContentType(ctx.ContentType()) {
+			// 只读取 maxBodySize 个字节，再把读过的部分拼回去，不影响后面的处理
+			body, _ := io.ReadAll(io.LimitReader(ctx.Request.Body, m.maxBodySize))
+			ctx.Request.Body = readCloser{
+				Reader: io.MultiReader(bytes.NewReader(body), ctx.Request.Body),
+				Closer: ctx.Request.Body,
+			}
+			fields = append(fields, logger.Any("req_body", string(body)))
+		}
+
+		var w *responseWriter
+		if m.allowRespBody {
+			w = &responseWriter{ResponseWriter: ctx.Writer, m: m}
+			ctx.Writer = w
+		}
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		fields = append(fields,
+			logger.Any("method", ctx.Request.Method),
+			logger.Any("route", ctx.FullPath()),
+			logger.Any("path", ctx.Request.URL.Path),
+			logger.Any("status", status),
+			logger.Any("latency", time.Since(start).String()),
+			logger.Any("ip", ctx.ClientIP()),
+		)
+		if w != nil {
+			fields = append(fields, logger.Any("resp_body", w.body.String()))
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			m.l.Error("访问日志", fields...)
+		case status >= http.StatusBadRequest:
+			m.l.Warn("访问日志", fields...)
+		default:
+			m.l.Info("访问日志", fields...)
+		}
+	}
+}
+
+func (m *MiddlewareBuilder) allowContentType(contentType string) bool {
+	for _, typ := range m.contentTypes {
+		if strings.HasPrefix(contentType, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseWriter 在写响应的同时记录前 maxBodySize 个字节
+type responseWriter struct {
+	gin.ResponseWriter
+	m    *MiddlewareBuilder
+	body bytes.Buffer
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseWriter) record(data []byte) {
+	left := w.m.maxBodySize - int64(w.body.Len())
+	if left <= 0 || !w.m.allowContentType(w.Header().Get("Content-Type")) {
+		return
+	}
+	if int64(len(data)) > left {
+		data = data[:left]
+	}
+	w.body.Write(data)
+}
@@ -0,0 +1,143 @@
+package accesslog
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"test/webook/pkg/logger"
+	"testing"
+)
+
+func TestMiddlewareBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := &mockLogger{}
+	server := gin.New()
+	server.Use(NewMiddlewareBuilder(l).AllowReqBody().AllowRespBody().MaxBodySize(8).Builder())
+	server.POST("/echo", func(ctx *gin.Context) {
+		// 记录日志不能影响 handler 读取完整的请求体
+		body, err := io.ReadAll(ctx.Request.Body)
+		require.NoError(t, err)
+		ctx.Data(http.StatusOK, ctx.ContentType(), body)
+	})
+	server.GET("/status/:code", func(ctx *gin.Context) {
+		code := http.StatusOK
+		switch ctx.Param("code") {
+		case "404":
+			code = http.StatusNotFound
+		case "500":
+			code = http.StatusInternalServerError
+		}
+		ctx.String(code, "status")
+	})
+
+	testCases := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+
+		wantLevel    string
+		wantStatus   int
+		wantReqBody  any
+		wantRespBody any
+	}{
+		{
+			name:         "超过上限的部分不记录",
+			method:       http.MethodPost,
+			path:         "/echo",
+			contentType:  "application/json",
+			body:         `{"name":"Tom"}`,
+			wantLevel:    "info",
+			wantStatus:   http.StatusOK,
+			wantReqBody:  `{"name":`,
+			wantRespBody: `{"name":`,
+		},
+		{
+			name:         "不在白名单里面的 Content-Type",
+			method:       http.MethodPost,
+			path:         "/echo",
+			contentType:  "application/octet-stream",
+			body:         "binary",
+			wantLevel:    "info",
+			wantStatus:   http.StatusOK,
+			wantRespBody: "",
+		},
+		{
+			name:         "4xx",
+			method:       http.MethodGet,
+			path:         "/status/404",
+			wantLevel:    "warn",
+			wantStatus:   http.StatusNotFound,
+			wantRespBody: "status",
+		},
+		{
+			name:         "5xx",
+			method:       http.MethodGet,
+			path:         "/status/500",
+			wantLevel:    "error",
+			wantStatus:   http.StatusInternalServerError,
+			wantRespBody: "status",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l.entries = nil
+			req, err := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			if tc.path == "/echo" {
+				assert.Equal(t, tc.body, recorder.Body.String())
+			}
+
+			require.Len(t, l.entries, 1)
+			entry := l.entries[0]
+			assert.Equal(t, tc.wantLevel, entry.level)
+			assert.Equal(t, tc.wantStatus, entry.fields["status"])
+			assert.Equal(t, tc.wantReqBody, entry.fields["req_body"])
+			assert.Equal(t, tc.wantRespBody, entry.fields["resp_body"])
+		})
+	}
+}
+
+type logEntry struct {
+	level  string
+	fields map[string]any
+}
+
+type mockLogger struct {
+	entries []logEntry
+}
+
+func (m *mockLogger) Debug(msg string, args ...logger.Field) {
+	m.log("debug", args)
+}
+
+func (m *mockLogger) Info(msg string, args ...logger.Field) {
+	m.log("info", args)
+}
+
+func (m *mockLogger) Warn(msg string, args ...logger.Field) {
+	m.log("warn", args)
+}
+
+func (m *mockLogger) Error(msg string, args ...logger.Field) {
+	m.log("error", args)
+}
+
+func (m *mockLogger) log(level string, args []logger.Field) {
+	fields := make(map[string]any, len(args))
+	for _, arg := range args {
+		fields[arg.Key] = arg.Value
+	}
+	m.entries = append(m.entries, logEntry{level: level, fields: fields})
+}
@@ -0,0 +1,52 @@
+package breaker
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"test/webook/pkg/breaker"
+)
+
+type MiddlewareBuilder struct {
+	breaker *breaker.Breaker
+	// 判断请求是否失败，默认 5xx 算失败
+	isFailure func(ctx *gin.Context) bool
+}
+
+func NewMiddlewareBuilder(b *breaker.Breaker) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		breaker: b,
+		isFailure: func(ctx *gin.Context) bool {
+			return ctx.Writer.Status() >= http.StatusInternalServerError
+		},
+	}
+}
+
+func (m *MiddlewareBuilder) IsFailure(fn func(ctx *gin.Context) bool) *MiddlewareBuilder {
+	m.isFailure = fn
+	return m
+}
+
+func (m *MiddlewareBuilder) Builder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		done, err := m.breaker.Allow()
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+
+		// 处理请求的时候 panic 了也要算失败
+		failed := true
+		defer func() {
+			if failed {
+				done(errRequestFailed)
+				return
+			}
+			done(nil)
+		}()
+		ctx.Next()
+		failed = m.isFailure(ctx)
+	}
+}
+
+var errRequestFailed = errors.New("request failed")
@@ -0,0 +1,87 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"test/webook/pkg/ginx/middleware/ratelimit"
+	"test/webook/pkg/limiter"
+	"test/webook/pkg/logger"
+	"time"
+)
+
+type MiddlewareBuilder struct {
+	limiter limiter.ConcurrencyLimiter
+	prefix  string
+	keyFn   ratelimit.KeyFunc
+	// 大于 0 的时候拿不到许可会排队等待，否则直接拒绝
+	waitTimeout time.Duration
+	l           logger.Logger
+}
+
+func NewMiddlewareBuilder(l limiter.ConcurrencyLimiter) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		limiter: l,
+		prefix:  "concurrency",
+		l:       logger.NewNoLogger(),
+	}
+}
+
+func (m *MiddlewareBuilder) Prefix(prefix string) *MiddlewareBuilder {
+	m.prefix = prefix
+	return m
+}
+
+// KeyFn 设置 key 的提取方式，不设置的话整个服务共用一个 key
+func (m *MiddlewareBuilder) KeyFn(fn ratelimit.KeyFunc) *MiddlewareBuilder {
+	m.keyFn = fn
+	return m
+}
+
+// Wait 拿不到许可的时候最多排队等待 timeout
+func (m *MiddlewareBuilder) Wait(timeout time.Duration) *MiddlewareBuilder {
+	m.waitTimeout = timeout
+	return m
+}
+
+func (m *MiddlewareBuilder) Logger(l logger.Logger) *MiddlewareBuilder {
+	m.l = l
+	return m
+}
+
+func (m *MiddlewareBuilder) Builder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		release, err := m.acquire(ctx)
+		switch {
+		case err == nil:
+		case errors.Is(err, limiter.ErrConcurrencyLimited) || errors.Is(err, context.DeadlineExceeded):
+			ctx.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		case errors.Is(err, context.Canceled):
+			// 排队的时候客户端断开了连接，响应也没人收，不需要记录
+			ctx.Abort()
+			return
+		default:
+			m.l.Error("获取并发许可失败", logger.Error(err))
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		defer release()
+		ctx.Next()
+	}
+}
+
+func (m *MiddlewareBuilder) acquire(ctx *gin.Context) (func(), error) {
+	key := m.prefix
+	if m.keyFn != nil {
+		key = key + ":" + m.keyFn(ctx)
+	}
+	if m.waitTimeout <= 0 {
+		return m.limiter.TryAcquire(ctx, key)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx.Request.Context(), m.waitTimeout)
+	defer cancel()
+	return m.limiter.Acquire(waitCtx, key)
+}
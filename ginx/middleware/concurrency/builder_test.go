@@ -0,0 +1,65 @@
+package concurrency
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"test/webook/pkg/limiter"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := limiter.NewLocalConcurrencyLimiter(1)
+	// 先把唯一的许可占住
+	release, err := l.TryAcquire(context.Background(), "concurrency")
+	require.NoError(t, err)
+	defer release()
+
+	h := NewMiddlewareBuilder(l).Wait(time.Second).Builder()
+
+	testCases := []struct {
+		name   string
+		reqCtx func() (context.Context, context.CancelFunc)
+
+		wantCode    int
+		wantWritten bool
+	}{
+		{
+			name: "排队超时",
+			reqCtx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 10*time.Millisecond)
+			},
+			wantCode:    http.StatusTooManyRequests,
+			wantWritten: true,
+		},
+		{
+			name: "排队的时候客户端断开",
+			reqCtx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reqCtx, cancel := tc.reqCtx()
+			defer cancel()
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(reqCtx)
+			h(ctx)
+
+			assert.True(t, ctx.IsAborted())
+			assert.Equal(t, tc.wantWritten, ctx.Writer.Written())
+			if tc.wantWritten {
+				assert.Equal(t, tc.wantCode, recorder.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"test/webook/pkg/ginx"
+	"test/webook/pkg/logger"
+)
+
+// MiddlewareBuilder 登录校验，校验通过之后 Claims[T] 放在 gin.Context 的 ginx.ClaimsKey 下面，
+// 可以直接配合 ginx.WrapClaims 使用
+type MiddlewareBuilder[T any] struct {
+	h           *Handler[T]
+	ignorePaths []string
+	l           logger.Logger
+}
+
+func NewMiddlewareBuilder[T any](h *Handler[T]) *MiddlewareBuilder[T] {
+	return &MiddlewareBuilder[T]{
+		h: h,
+		l: logger.NewNoLogger(),
+	}
+}
+
+// IgnorePaths 不需要登录的路径，以 /* 结尾的按照前缀匹配，例如 /users/login、/public/*
+func (m *MiddlewareBuilder[T]) IgnorePaths(paths ...string) *MiddlewareBuilder[T] {
+	m.ignorePaths = append(m.ignorePaths, paths...)
+	return m
+}
+
+func (m *MiddlewareBuilder[T]) Logger(l logger.Logger) *MiddlewareBuilder[T] {
+	m.l = l
+	return m
+}
+
+func (m *MiddlewareBuilder[T]) Builder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if m.ignore(ctx.Request.URL.Path) {
+			return
+		}
+
+		claims, err := m.h.parse(ctx, m.h.accessKey, tokenTypeAccess)
+		if err != nil {
+			// token 本身有问题是正常的未登录，不需要记录
+			if errors.Is(err, errCheckRevoked) {
+				m.l.Error("查询 token 是否撤销失败", logger.Error(err), logger.Any("path", ctx.Request.URL.Path))
+			}
+			ginx.AbortWithError(ctx, ginx.ErrUnauthorized)
+			return
+		}
+		ctx.Set(ginx.ClaimsKey, claims)
+	}
+}
+
+func (m *MiddlewareBuilder[T]) ignore(path string) bool {
+	for _, p := range m.ignorePaths {
+		if prefix, ok := strings.CutSuffix(p, "/*"); ok {
+			if strings.HasPrefix(path, prefix+"/") || path == prefix {
+				return true
+			}
+			continue
+		}
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
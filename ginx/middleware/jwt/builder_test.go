@@ -0,0 +1,168 @@
+package jwt
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"test/webook/pkg/ginx"
+	"testing"
+	"time"
+)
+
+type User struct {
+	Id int64 `json:"id"`
+}
+
+func TestMiddlewareBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Now()
+	revoker := newMemoryRevoker()
+	// access 和 refresh 用同一个 key，依旧不能混用
+	h := NewHandler[User]([]byte("key"), []byte("key"), revoker)
+	h.now = func() time.Time {
+		return now
+	}
+
+	server := gin.New()
+	server.POST("/login", func(ctx *gin.Context) {
+		require.NoError(t, h.SetLoginToken(ctx, User{Id: 123}))
+	})
+	server.Use(NewMiddlewareBuilder(h).IgnorePaths("/public/*", "/ping").Builder())
+	server.GET("/profile", func(ctx *gin.Context) {
+		val, _ := ctx.Get(ginx.ClaimsKey)
+		ctx.JSON(http.StatusOK, val.(Claims[User]).Data)
+	})
+	ok := func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	}
+	server.GET("/ping", ok)
+	server.GET("/public/a", ok)
+	server.GET("/public", ok)
+	server.GET("/publicx", ok)
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/login", nil))
+	access := recorder.Header().Get("x-jwt-token")
+	refresh := recorder.Header().Get("x-refresh-token")
+	require.NotEmpty(t, access)
+	require.NotEmpty(t, refresh)
+
+	testCases := []struct {
+		name   string
+		before func(t *testing.T)
+		path   string
+		token  string
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "忽略的路径",
+			path:     "/ping",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "忽略的前缀",
+			path:     "/public/a",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "前缀本身",
+			path:     "/public",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "前缀不是完整的路径段",
+			path:     "/publicx",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "没有 token",
+			path:     "/profile",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "登录成功",
+			path:     "/profile",
+			token:    access,
+			wantCode: http.StatusOK,
+			wantBody: `{"id":123}`,
+		},
+		{
+			name:     "refresh token 不能当 access token 用",
+			path:     "/profile",
+			token:    refresh,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "过期",
+			before: func(t *testing.T) {
+				now = now.Add(time.Hour)
+			},
+			path:     "/profile",
+			token:    access,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "撤销",
+			before: func(t *testing.T) {
+				now = now.Add(-time.Hour)
+				claims := parseClaims(t, access)
+				_, err := revoker.Revoke(context.Background(), claims.Ssid, time.Hour)
+				require.NoError(t, err)
+			},
+			path:     "/profile",
+			token:    access,
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.before != nil {
+				tc.before(t)
+			}
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantBody != "" {
+				assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+// memoryRevoker 测试用，语义和 RedisRevoker 一致
+type memoryRevoker struct {
+	mu      sync.Mutex
+	revoked map[string]struct{}
+}
+
+func newMemoryRevoker() *memoryRevoker {
+	return &memoryRevoker{revoked: map[string]struct{}{}}
+}
+
+func (r *memoryRevoker) Revoke(ctx context.Context, ssid string, ttl time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.revoked[ssid]; ok {
+		return false, nil
+	}
+	r.revoked[ssid] = struct{}{}
+	return true, nil
+}
+
+func (r *memoryRevoker) IsRevoked(ctx context.Context, ssid string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.revoked[ssid]
+	return ok, nil
+}
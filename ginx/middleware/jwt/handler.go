@@ -0,0 +1,178 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"strings"
+	"test/webook/pkg/ginx"
+	"time"
+)
+
+var (
+	ErrTokenRevoked = errors.New("token revoked")
+	errNoClaims     = errors.New("no claims in context")
+	errCheckRevoked = errors.New("check revoked failed")
+)
+
+// Handler 负责生成、解析、刷新和撤销 token。
+// 登录之后同时返回 access token 和 refresh token，access token 过期之后用 refresh token 换新的，
+// 每次刷新都会换一个新的会话，旧的 refresh token 不能再用
+type Handler[T any] struct {
+	accessKey  []byte
+	refreshKey []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	method     jwt.SigningMethod
+	revoker    Revoker
+	now        func() time.Time
+
+	accessHeader  string
+	refreshHeader string
+}
+
+// NewHandler revoker 不能是 nil，没有它 refresh token 可以重复使用，退出登录也不会生效，所以这里直接 panic
+func NewHandler[T any](accessKey, refreshKey []byte, revoker Revoker) *Handler[T] {
+	if revoker == nil {
+		panic("jwt: revoker 不能是 nil")
+	}
+	return &Handler[T]{
+		accessKey:     accessKey,
+		refreshKey:    refreshKey,
+		accessTTL:     time.Minute * 30,
+		refreshTTL:    time.Hour * 24 * 7,
+		method:        jwt.SigningMethodHS256,
+		revoker:       revoker,
+		now:           time.Now,
+		accessHeader:  "x-jwt-token",
+		refreshHeader: "x-refresh-token",
+	}
+}
+
+func (h *Handler[T]) SetTTL(access, refresh time.Duration) *Handler[T] {
+	h.accessTTL = access
+	h.refreshTTL = refresh
+	return h
+}
+
+// SetLoginToken 登录成功之后调用，生成新的会话，token 放在响应头里面
+func (h *Handler[T]) SetLoginToken(ctx *gin.Context, data T) error {
+	ssid, err := newSsid()
+	if err != nil {
+		return err
+	}
+	err = h.SetRefreshToken(ctx, ssid, data)
+	if err != nil {
+		return err
+	}
+	return h.SetAccessToken(ctx, ssid, data)
+}
+
+func (h *Handler[T]) SetAccessToken(ctx *gin.Context, ssid string, data T) error {
+	token, err := h.sign(h.accessKey, h.accessTTL, tokenTypeAccess, ssid, data)
+	if err != nil {
+		return err
+	}
+	ctx.Header(h.accessHeader, token)
+	return nil
+}
+
+func (h *Handler[T]) SetRefreshToken(ctx *gin.Context, ssid string, data T) error {
+	token, err := h.sign(h.refreshKey, h.refreshTTL, tokenTypeRefresh, ssid, data)
+	if err != nil {
+		return err
+	}
+	ctx.Header(h.refreshHeader, token)
+	return nil
+}
+
+// Refresh 请求头里面带的是 refresh token，校验通过之后撤销旧的会话，重新生成一对 token
+func (h *Handler[T]) Refresh(ctx *gin.Context) error {
+	claims, err := h.parse(ctx, h.refreshKey, tokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+	// 先撤销，保证同一个 refresh token 只能用一次。
+	// 并发的请求都能通过 parse 里面的检查，只有真正撤销成功的那个才能拿到新的 token
+	ok, err := h.revoker.Revoke(ctx, claims.Ssid, h.refreshTTL)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTokenRevoked
+	}
+	return h.SetLoginToken(ctx, claims.Data)
+}
+
+// Logout 撤销当前会话，需要先经过登录校验的中间件
+func (h *Handler[T]) Logout(ctx *gin.Context) error {
+	val, _ := ctx.Get(ginx.ClaimsKey)
+	claims, ok := val.(Claims[T])
+	if !ok {
+		return errNoClaims
+	}
+	ctx.Header(h.accessHeader, "")
+	ctx.Header(h.refreshHeader, "")
+	// 已经被撤销过也算退出成功
+	_, err := h.revoker.Revoke(ctx, claims.Ssid, h.refreshTTL)
+	return err
+}
+
+// parse 从 Authorization 头解析 token，校验签名、过期时间、token 类型和会话是否已经被撤销。
+// 校验类型是为了 accessKey 和 refreshKey 一样的时候，两种 token 也不能混用
+func (h *Handler[T]) parse(ctx *gin.Context, key []byte, typ string) (Claims[T], error) {
+	var claims Claims[T]
+	token, err := jwt.ParseWithClaims(ExtractToken(ctx), &claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{h.method.Alg()}), jwt.WithTimeFunc(h.now))
+	if err != nil {
+		return claims, err
+	}
+	if !token.Valid || claims.Type != typ {
+		return claims, jwt.ErrTokenInvalidClaims
+	}
+	revoked, err := h.revoker.IsRevoked(ctx, claims.Ssid)
+	if err != nil {
+		return claims, fmt.Errorf("%w: %w", errCheckRevoked, err)
+	}
+	if revoked {
+		return claims, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+func (h *Handler[T]) sign(key []byte, ttl time.Duration, typ, ssid string, data T) (string, error) {
+	now := h.now()
+	claims := Claims[T]{
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Type: typ,
+		Ssid: ssid,
+		Data: data,
+	}
+	return jwt.NewWithClaims(h.method, claims).SignedString(key)
+}
+
+// ExtractToken 从 Authorization: Bearer xxx 里面取出 token
+func ExtractToken(ctx *gin.Context) string {
+	header := ctx.GetHeader("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+func newSsid() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
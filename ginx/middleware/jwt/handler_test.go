@@ -0,0 +1,108 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHandler_Refresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler[User]([]byte("access"), []byte("refresh"), newMemoryRevoker())
+
+	access, refresh := login(t, h)
+
+	// access token 不能用来刷新
+	_, err := callRefresh(h, access)
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+
+	header, err := callRefresh(h, refresh)
+	require.NoError(t, err)
+	newRefresh := header.Get("x-refresh-token")
+	require.NotEmpty(t, header.Get("x-jwt-token"))
+	require.NotEmpty(t, newRefresh)
+	// 每次刷新都是新的会话
+	assert.NotEqual(t, parseClaims(t, refresh).Ssid, parseClaims(t, newRefresh).Ssid)
+	assert.Equal(t, User{Id: 123}, parseClaims(t, newRefresh).Data)
+
+	// 旧的 refresh token 不能再用
+	_, err = callRefresh(h, refresh)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+
+	_, err = callRefresh(h, newRefresh)
+	require.NoError(t, err)
+}
+
+func TestNewHandler_NilRevoker(t *testing.T) {
+	assert.Panics(t, func() {
+		NewHandler[User]([]byte("access"), []byte("refresh"), nil)
+	})
+}
+
+func TestHandler_RefreshSameKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler[User]([]byte("key"), []byte("key"), newMemoryRevoker())
+	access, _ := login(t, h)
+
+	_, err := callRefresh(h, access)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidClaims)
+}
+
+func TestHandler_RefreshConcurrent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler[User]([]byte("access"), []byte("refresh"), newMemoryRevoker())
+	_, refresh := login(t, h)
+
+	var (
+		wg      sync.WaitGroup
+		success atomic.Int32
+		revoked atomic.Int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := callRefresh(h, refresh)
+			switch {
+			case err == nil:
+				success.Add(1)
+			case assert.ErrorIs(t, err, ErrTokenRevoked):
+				revoked.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	// 同一个 refresh token 并发刷新，只有一个能拿到新的 token
+	assert.Equal(t, int32(1), success.Load())
+	assert.Equal(t, int32(9), revoked.Load())
+}
+
+func login(t *testing.T, h *Handler[User]) (string, string) {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	require.NoError(t, h.SetLoginToken(ctx, User{Id: 123}))
+	return recorder.Header().Get("x-jwt-token"), recorder.Header().Get("x-refresh-token")
+}
+
+func callRefresh(h *Handler[User], token string) (http.Header, error) {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/refresh", nil)
+	ctx.Request.Header.Set("Authorization", "Bearer "+token)
+	err := h.Refresh(ctx)
+	return recorder.Header(), err
+}
+
+// parseClaims 不校验签名，只是取出里面的 claims
+func parseClaims(t *testing.T, token string) Claims[User] {
+	var claims Claims[User]
+	_, _, err := jwt.NewParser().ParseUnverified(token, &claims)
+	require.NoError(t, err)
+	return claims
+}
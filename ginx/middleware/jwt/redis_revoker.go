@@ -0,0 +1,35 @@
+package jwt
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// RedisRevoker 每个退出登录的会话一个 key，过期时间和 refresh token 一致，
+// 过期之后 token 本身也失效了，不需要再记录
+type RedisRevoker struct {
+	cmd    redis.Cmdable
+	prefix string
+}
+
+func NewRedisRevoker(cmd redis.Cmdable) *RedisRevoker {
+	return &RedisRevoker{
+		cmd:    cmd,
+		prefix: "jwt:revoked",
+	}
+}
+
+// Revoke 用 SET NX，key 已经存在说明别的请求先撤销了
+func (r *RedisRevoker) Revoke(ctx context.Context, ssid string, ttl time.Duration) (bool, error) {
+	return r.cmd.SetNX(ctx, r.key(ssid), "", ttl).Result()
+}
+
+func (r *RedisRevoker) IsRevoked(ctx context.Context, ssid string) (bool, error) {
+	cnt, err := r.cmd.Exists(ctx, r.key(ssid)).Result()
+	return cnt > 0, err
+}
+
+func (r *RedisRevoker) key(ssid string) string {
+	return r.prefix + ":" + ssid
+}
@@ -0,0 +1,29 @@
+package jwt
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+// Claims access token 和 refresh token 共用的 claims，Data 是业务自定义的数据，例如用户 ID。
+// 同一次登录生成的 token 共享一个 Ssid，Type 区分是 access token 还是 refresh token
+type Claims[T any] struct {
+	jwt.RegisteredClaims
+	Type string `json:"typ"`
+	Ssid string `json:"ssid"`
+	Data T      `json:"data"`
+}
+
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+// Revoker 记录已经退出登录的会话，被撤销的会话对应的 token 都会失效
+type Revoker interface {
+	// Revoke 撤销会话，返回 true 表示是这次调用撤销的，false 表示之前已经被撤销了。
+	// 检查和撤销必须是原子的，Refresh 靠这个保证并发的情况下同一个 refresh token 也只能用一次
+	Revoke(ctx context.Context, ssid string, ttl time.Duration) (bool, error)
+	IsRevoked(ctx context.Context, ssid string) (bool, error)
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"strconv"
+	"test/webook/pkg/ginx"
+	"test/webook/pkg/prometheusx"
 	"time"
 )
 
@@ -13,18 +15,25 @@ type Builder struct {
 	Name       string
 	Help       string
 	InstanceId string
+	// Registerer 为空的时候注册到 prometheus.DefaultRegisterer。
+	// 同样的指标重复注册的时候复用已经注册的，所以同一个 Builder 可以 Build 多次
+	Registerer prometheus.Registerer
+	// Buckets 响应时间的分桶，单位是秒，为空的时候使用 prometheus.DefBuckets
+	Buckets []float64
+	// SizeBuckets 请求和响应大小的分桶，单位是字节，为空的时候是 100B 到 10MB
+	SizeBuckets []float64
 }
 
+// BuildResponseTime 用 Summary 统计响应时间，单位是毫秒。
+// Summary 的分位数没办法跨实例聚合，多实例部署的时候用 BuildHistogram
 func (b *Builder) BuildResponseTime() gin.HandlerFunc {
 	labels := []string{"method", "pattern", "status"}
-	vector := prometheus.NewSummaryVec(prometheus.SummaryOpts{
-		Namespace: b.Namespace,
-		Subsystem: b.Subsystem,
-		Name:      b.Name + "_resp_time",
-		Help:      b.Help,
-		ConstLabels: map[string]string{
-			"instance_id": b.InstanceId,
-		},
+	vector := prometheusx.MustRegister(b.registerer(), prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Namespace:   b.Namespace,
+		Subsystem:   b.Subsystem,
+		Name:        b.Name + "_resp_time",
+		Help:        b.Help,
+		ConstLabels: b.constLabels(),
 		Objectives: map[float64]float64{
 			0.5:   0.01,
 			0.75:  0.01,
@@ -33,8 +42,7 @@ func (b *Builder) BuildResponseTime() gin.HandlerFunc {
 			0.99:  0.001,
 			0.999: 0.0001,
 		},
-	}, labels)
-	prometheus.MustRegister(vector)
+	}, labels))
 	return func(ctx *gin.Context) {
 		start := time.Now()
 
@@ -47,18 +55,105 @@ func (b *Builder) BuildResponseTime() gin.HandlerFunc {
 	}
 }
 
-func (b *Builder) BuildActiveRequest() gin.HandlerFunc {
-	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
-		Namespace: b.Namespace,
-		Subsystem: b.Subsystem,
-		Name:      b.Name + "_active_req",
-		Help:      b.Help,
-		ConstLabels: map[string]string{
-			"instance_id": b.InstanceId,
-		},
-	})
+// BuildHistogram 用 Histogram 统计响应时间，单位是秒
+func (b *Builder) BuildHistogram() gin.HandlerFunc {
+	buckets := b.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	vector := prometheusx.MustRegister(b.registerer(), prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   b.Namespace,
+		Subsystem:   b.Subsystem,
+		Name:        b.Name + "_resp_duration_seconds",
+		Help:        b.Help,
+		ConstLabels: b.constLabels(),
+		Buckets:     buckets,
+	}, []string{"method", "pattern", "status"}))
+	return func(ctx *gin.Context) {
+		start := time.Now()
 
-	prometheus.MustRegister(gauge)
+		defer func() {
+			vector.WithLabelValues(ctx.Request.Method, ctx.FullPath(), strconv.Itoa(ctx.Writer.Status())).
+				Observe(time.Since(start).Seconds())
+		}()
+
+		ctx.Next()
+	}
+}
+
+// BuildSize 统计请求和响应的大小，请求大小取的是 Content-Length
+func (b *Builder) BuildSize() gin.HandlerFunc {
+	buckets := b.SizeBuckets
+	if len(buckets) == 0 {
+		buckets = prometheus.ExponentialBuckets(100, 10, 6)
+	}
+	labels := []string{"method", "pattern"}
+	reqSize := prometheusx.MustRegister(b.registerer(), prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   b.Namespace,
+		Subsystem:   b.Subsystem,
+		Name:        b.Name + "_req_size_bytes",
+		Help:        b.Help,
+		ConstLabels: b.constLabels(),
+		Buckets:     buckets,
+	}, labels))
+	respSize := prometheusx.MustRegister(b.registerer(), prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   b.Namespace,
+		Subsystem:   b.Subsystem,
+		Name:        b.Name + "_resp_size_bytes",
+		Help:        b.Help,
+		ConstLabels: b.constLabels(),
+		Buckets:     buckets,
+	}, labels))
+	return func(ctx *gin.Context) {
+		defer func() {
+			method, pattern := ctx.Request.Method, ctx.FullPath()
+			// 分块传输的请求拿不到长度，是 -1
+			if ctx.Request.ContentLength >= 0 {
+				reqSize.WithLabelValues(method, pattern).Observe(float64(ctx.Request.ContentLength))
+			}
+			// 没有写响应的时候是 -1
+			size := ctx.Writer.Size()
+			if size < 0 {
+				size = 0
+			}
+			respSize.WithLabelValues(method, pattern).Observe(float64(size))
+		}()
+
+		ctx.Next()
+	}
+}
+
+// BuildErrorCode 按照 ginx.Result 的错误码统计，只统计不为 0 的错误码。
+// 需要通过 ginx 的 Wrap 系列方法或者 ginx.JSON 响应，才能拿到错误码
+func (b *Builder) BuildErrorCode() gin.HandlerFunc {
+	vector := prometheusx.MustRegister(b.registerer(), prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   b.Namespace,
+		Subsystem:   b.Subsystem,
+		Name:        b.Name + "_error_code_total",
+		Help:        b.Help,
+		ConstLabels: b.constLabels(),
+	}, []string{"method", "pattern", "code"}))
+	return func(ctx *gin.Context) {
+		defer func() {
+			code := ctx.GetInt(ginx.ResultCodeKey)
+			if code == 0 {
+				return
+			}
+			vector.WithLabelValues(ctx.Request.Method, ctx.FullPath(), strconv.Itoa(code)).Inc()
+		}()
+
+		ctx.Next()
+	}
+}
+
+func (b *Builder) BuildActiveRequest() gin.HandlerFunc {
+	gauge := prometheusx.MustRegister(b.registerer(), prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   b.Namespace,
+		Subsystem:   b.Subsystem,
+		Name:        b.Name + "_active_req",
+		Help:        b.Help,
+		ConstLabels: b.constLabels(),
+	}))
 
 	return func(ctx *gin.Context) {
 		gauge.Inc()
@@ -66,3 +161,16 @@ func (b *Builder) BuildActiveRequest() gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+func (b *Builder) registerer() prometheus.Registerer {
+	if b.Registerer == nil {
+		return prometheus.DefaultRegisterer
+	}
+	return b.Registerer
+}
+
+func (b *Builder) constLabels() prometheus.Labels {
+	return prometheus.Labels{
+		"instance_id": b.InstanceId,
+	}
+}
@@ -0,0 +1,75 @@
+package prometheus
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"test/webook/pkg/ginx"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := prometheus.NewRegistry()
+	b := &Builder{
+		Namespace:  "webook",
+		Subsystem:  "web",
+		Name:       "gin_http",
+		Help:       "HTTP 接口",
+		InstanceId: "instance-1",
+		Registerer: reg,
+		Buckets:    []float64{0.01, 0.1, 1},
+	}
+
+	server := gin.New()
+	server.Use(b.BuildHistogram(), b.BuildSize(), b.BuildErrorCode())
+	// 重复构建不会 panic，复用已经注册的指标
+	require.NotPanics(t, func() {
+		b.BuildHistogram()
+		b.BuildSize()
+		b.BuildErrorCode()
+	})
+	server.POST("/ok", ginx.Wrap(func(ctx *gin.Context) (ginx.Result, error) {
+		return ginx.Result{Msg: "OK"}, nil
+	}))
+	server.POST("/fail", ginx.Wrap(func(ctx *gin.Context) (ginx.Result, error) {
+		return ginx.Result{}, ginx.ErrInvalidParam.Wrap(errors.New("bad"))
+	}))
+
+	for _, path := range []string{"/ok", "/fail", "/fail"} {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(`{"id":1}`))
+		require.NoError(t, err)
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// 按照 method 和 pattern 区分，两个接口各一条
+	assert.Equal(t, 2, testutil.CollectAndCount(reg, "webook_web_gin_http_req_size_bytes"))
+	assert.Equal(t, 2, testutil.CollectAndCount(reg, "webook_web_gin_http_resp_size_bytes"))
+	assert.Equal(t, 2, testutil.CollectAndCount(reg, "webook_web_gin_http_resp_duration_seconds"))
+
+	// 成功的请求错误码是 0，不统计
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	var found bool
+	for _, mf := range mfs {
+		if mf.GetName() != "webook_web_gin_http_error_code_total" {
+			continue
+		}
+		found = true
+		require.Len(t, mf.GetMetric(), 1)
+		assert.Equal(t, float64(2), mf.GetMetric()[0].GetCounter().GetValue())
+		labels := map[string]string{}
+		for _, l := range mf.GetMetric()[0].GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		assert.Equal(t, "2", labels["code"])
+		assert.Equal(t, "/fail", labels["pattern"])
+	}
+	assert.True(t, found)
+}
@@ -2,40 +2,224 @@ package ratelimit
 
 import (
 	_ "embed"
-	"fmt"
+	"errors"
 	"github.com/gin-gonic/gin"
-	"log"
+	"go.uber.org/atomic"
+	"math"
 	"net/http"
+	"strconv"
 	"test/webook/pkg/limiter"
+	"test/webook/pkg/logger"
+	"time"
 )
 
+// 限流器出错时的处理策略
+const (
+	// ErrPolicyDeny 拒绝请求，默认策略
+	ErrPolicyDeny = "deny"
+	// ErrPolicyAllow 直接放行
+	ErrPolicyAllow = "allow"
+	// ErrPolicyFallback 交给降级的限流器，一般是本地限流器
+	ErrPolicyFallback = "fallback"
+)
+
+var errCircuitOpen = errors.New("limiter circuit open")
+
 type MiddlewareBuilder struct {
 	limiter limiter.Limiter
+	prefix  string
+	keyFn   KeyFunc
+	costFn  func(ctx *gin.Context) int64
+	l       logger.Logger
+
+	errPolicy string
+	fallback  limiter.Limiter
+
+	// 连续出错 maxFailures 次之后，cooldown 时间内不再调用 limiter
+	maxFailures int64
+	cooldown    time.Duration
+	failures    *atomic.Int64
+	openUntil   *atomic.Int64
 }
 
 func NewMiddlewareBuilder(l limiter.Limiter) *MiddlewareBuilder {
 	return &MiddlewareBuilder{
-		limiter: l,
+		limiter:   l,
+		prefix:    "rate_limit",
+		l:         logger.NewNoLogger(),
+		errPolicy: ErrPolicyDeny,
+		failures:  atomic.NewInt64(0),
+		openUntil: atomic.NewInt64(0),
 	}
 }
 
+// Prefix 限流 key 的前缀，不同的限流规则应该使用不同的前缀
+func (m *MiddlewareBuilder) Prefix(prefix string) *MiddlewareBuilder {
+	m.prefix = prefix
+	return m
+}
+
+// KeyFn 设置 key 的提取方式，不设置的话整个服务共用一个 key
+func (m *MiddlewareBuilder) KeyFn(fn KeyFunc) *MiddlewareBuilder {
+	m.keyFn = fn
+	return m
+}
+
+// Cost 计算每个请求消耗的配额，例如批量接口按照批次大小计算。
+// 不设置的话每个请求消耗一个配额，返回值小于 1 的按照 1 计算。
+// 基于 ZSET 的滑动窗口限流器每个配额写一个成员，配额大的时候换成滑动窗口计数或者令牌桶
+func (m *MiddlewareBuilder) Cost(fn func(ctx *gin.Context) int64) *MiddlewareBuilder {
+	m.costFn = fn
+	return m
+}
+
+func (m *MiddlewareBuilder) Logger(l logger.Logger) *MiddlewareBuilder {
+	m.l = l
+	return m
+}
+
+// DenyOnError 限流器出错时拒绝请求
+func (m *MiddlewareBuilder) DenyOnError() *MiddlewareBuilder {
+	m.errPolicy = ErrPolicyDeny
+	return m
+}
+
+// AllowOnError 限流器出错时放行请求
+func (m *MiddlewareBuilder) AllowOnError() *MiddlewareBuilder {
+	m.errPolicy = ErrPolicyAllow
+	return m
+}
+
+// FallbackOnError 限流器出错时使用 fallback 限流，例如 limiter.LocalSlideWindowLimiter。
+// fallback 是 limiter.FeedbackLimiter 的话，它放行的请求结束之后同样会调用 Done。
+// fallback 不能是 nil，不需要降级的话用 DenyOnError 或者 AllowOnError
+func (m *MiddlewareBuilder) FallbackOnError(fallback limiter.Limiter) *MiddlewareBuilder {
+	if fallback == nil {
+		panic("ratelimit: fallback 不能是 nil")
+	}
+	m.errPolicy = ErrPolicyFallback
+	m.fallback = fallback
+	return m
+}
+
+// Circuit 限流器连续出错 maxFailures 次之后，在 cooldown 时间内不再调用它，
+// 直接按照出错处理
+func (m *MiddlewareBuilder) Circuit(maxFailures int64, cooldown time.Duration) *MiddlewareBuilder {
+	m.maxFailures = maxFailures
+	m.cooldown = cooldown
+	return m
+}
+
 func (m *MiddlewareBuilder) Builder() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		limit, err := m.limiter.Limit(ctx, m.key())
+		key := m.key(ctx)
+		n := m.cost(ctx)
+		limited, err := m.limit(ctx, key, n)
+		// 真正做出决策的限流器，出错降级之后是 fallback，直接放行的话没有
+		decider := m.limiter
+		if err != nil {
+			decider = nil
+			if m.errPolicy == ErrPolicyFallback {
+				decider = m.fallback
+			}
+			limited, err = m.onError(ctx, key, n, err)
+		}
 		if err != nil {
-			log.Println("请求频繁", err)
-			//这里需要根据需求判断是否限流
 			ctx.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
 
-		if limit {
+		if limited {
 			ctx.AbortWithStatus(http.StatusTooManyRequests)
 			return
 		}
+
+		if fb, ok := decider.(limiter.FeedbackLimiter); ok {
+			start := time.Now()
+			defer func() {
+				fb.Done(ctx, key, time.Since(start))
+			}()
+			ctx.Next()
+		}
 	}
 }
 
-func (m *MiddlewareBuilder) key() string {
-	return fmt.Sprintf("rate_limit")
+func (m *MiddlewareBuilder) limit(ctx *gin.Context, key string, n int64) (bool, error) {
+	if time.Now().UnixMilli() < m.openUntil.Load() {
+		return false, errCircuitOpen
+	}
+
+	limited, err := decide(ctx, m.limiter, key, n)
+	if err == nil {
+		m.failures.Store(0)
+		return limited, nil
+	}
+
+	m.l.Error("限流器出错", logger.Error(err), logger.Any("key", key))
+	if m.maxFailures > 0 && m.failures.Inc() >= m.maxFailures {
+		m.failures.Store(0)
+		m.openUntil.Store(time.Now().Add(m.cooldown).UnixMilli())
+		m.l.Warn("限流器连续出错，暂停调用",
+			logger.Any("failures", m.maxFailures),
+			logger.Any("cooldown", m.cooldown.String()))
+	}
+	return false, err
+}
+
+func (m *MiddlewareBuilder) onError(ctx *gin.Context, key string, n int64, err error) (bool, error) {
+	switch m.errPolicy {
+	case ErrPolicyAllow:
+		return false, nil
+	case ErrPolicyFallback:
+		limited, er := decide(ctx, m.fallback, key, n)
+		if er != nil {
+			m.l.Error("降级限流器出错", logger.Error(er), logger.Any("key", key))
+		}
+		return limited, er
+	default:
+		return false, err
+	}
+}
+
+// decide 限流器支持 limiter.DecisionLimiter 的时候，顺便把配额信息写到响应头里
+func decide(ctx *gin.Context, l limiter.Limiter, key string, n int64) (bool, error) {
+	dl, ok := l.(limiter.DecisionLimiter)
+	if !ok {
+		return l.LimitN(ctx, key, n)
+	}
+
+	d, err := dl.DecideN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	setHeaders(ctx, d)
+	return !d.Allowed, nil
+}
+
+func setHeaders(ctx *gin.Context, d limiter.Decision) {
+	ctx.Header("X-RateLimit-Limit", strconv.FormatInt(d.Limit, 10))
+	ctx.Header("X-RateLimit-Remaining", strconv.FormatInt(d.Remaining, 10))
+	ctx.Header("X-RateLimit-Reset", strconv.FormatInt(d.ResetAt.Unix(), 10))
+	if !d.Allowed {
+		ctx.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(d.RetryAfter.Seconds())), 10))
+	}
+}
+
+func (m *MiddlewareBuilder) cost(ctx *gin.Context) int64 {
+	if m.costFn == nil {
+		return 1
+	}
+	// 客户端传一个 0 或者负数的批次大小也至少消耗一个配额
+	n := m.costFn(ctx)
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func (m *MiddlewareBuilder) key(ctx *gin.Context) string {
+	if m.keyFn == nil {
+		return m.prefix
+	}
+	return m.prefix + ":" + m.keyFn(ctx)
 }
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"test/webook/pkg/limiter"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareBuilder_FallbackOnError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	assert.Panics(t, func() {
+		NewMiddlewareBuilder(errLimiter{}).FallbackOnError(nil)
+	})
+
+	server := gin.New()
+	server.Use(NewMiddlewareBuilder(errLimiter{}).
+		FallbackOnError(limiter.NewLocalSlideWindowLimiter(time.Minute, 1)).
+		Builder())
+	server.GET("/", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	codes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		codes = append(codes, recorder.Code)
+	}
+	// 限流器出错之后由 fallback 限流
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+type errLimiter struct{}
+
+func (errLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return false, errors.New("mock error")
+}
+
+func (errLimiter) LimitN(ctx context.Context, key string, n int64) (bool, error) {
+	return false, errors.New("mock error")
+}
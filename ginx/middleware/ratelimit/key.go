@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+// KeyFunc 从请求中提取限流的 key
+type KeyFunc func(ctx *gin.Context) string
+
+// Static 所有请求共用同一个 key，也就是整个服务一起限流
+func Static(key string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		return key
+	}
+}
+
+// ClientIP 按客户端 IP 限流。
+// 可信代理通过 gin.Engine 的 SetTrustedProxies 和 RemoteIPHeaders 配置
+func ClientIP() KeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.ClientIP()
+	}
+}
+
+// UserID 按登录用户限流，用户 ID 由登录校验的中间件通过 ctx.Set(ctxKey, uid) 放进来。
+// 没有登录的请求退化成按 IP 限流
+func UserID(ctxKey string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		uid, ok := ctx.Get(ctxKey)
+		if !ok || uid == nil {
+			return "ip:" + ctx.ClientIP()
+		}
+		return fmt.Sprintf("uid:%v", uid)
+	}
+}
+
+// Route 按路由限流，使用注册时的路由模式（例如 /users/:id）而不是实际路径，
+// 避免每个 id 都生成一个 key
+func Route() KeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.Request.Method + " " + ctx.FullPath()
+	}
+}
+
+// Header 按请求头的值限流，例如 API Key
+func Header(name string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.GetHeader(name)
+	}
+}
+
+// Prefix 给 key 加上前缀
+func Prefix(prefix string, fn KeyFunc) KeyFunc {
+	return func(ctx *gin.Context) string {
+		return prefix + ":" + fn(ctx)
+	}
+}
+
+// Compose 组合多个 KeyFunc，例如 Compose(Route(), ClientIP()) 就是每个 IP 在每个接口上单独限流
+func Compose(fns ...KeyFunc) KeyFunc {
+	return func(ctx *gin.Context) string {
+		segs := make([]string, 0, len(fns))
+		for _, fn := range fns {
+			segs = append(segs, fn(ctx))
+		}
+		return strings.Join(segs, ":")
+	}
+}
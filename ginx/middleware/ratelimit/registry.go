@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/atomic"
+	"net/http"
+	"os"
+	"strings"
+	"test/webook/pkg/ginx"
+	"test/webook/pkg/limiter"
+	"test/webook/pkg/logger"
+	"time"
+)
+
+var (
+	errUnknownKeyFn     = errors.New("unknown key func")
+	errUnknownLimiter   = errors.New("unknown limiter")
+	errDuplicateRule    = errors.New("duplicate rule")
+	errInvalidThreshold = errors.New("threshold must be positive")
+	errInvalidWindow    = errors.New("window must be positive")
+)
+
+// Rule 路由级别的限流规则
+type Rule struct {
+	// 路由模式，和 ctx.FullPath() 比较，例如 /users/:id
+	Path string `json:"path"`
+	// 为空表示匹配所有方法
+	Method string `json:"method"`
+	// key 的提取方式，对应 Registry.RegisterKeyFn 注册的名字，为空表示这个路由整体限流
+	Key string `json:"key"`
+	// 限流器，对应 Registry.RegisterLimiter 注册的名字
+	Limiter string `json:"limiter"`
+	// 窗口大小，例如 1s、1m
+	Window    string `json:"window"`
+	Threshold int64  `json:"threshold"`
+}
+
+// LimiterFactory 根据规则里面的窗口和阈值创建限流器
+type LimiterFactory func(window time.Duration, threshold int64) limiter.Limiter
+
+// Registry 集中管理各个路由的限流规则，规则可以从文件热加载
+type Registry struct {
+	keyFns    map[string]KeyFunc
+	factories map[string]LimiterFactory
+	l         logger.Logger
+	rules     *atomic.Pointer[ruleSet]
+}
+
+type ruleSet struct {
+	rules []Rule
+	// method + " " + path => 限流中间件，method 为空的规则用 " " + path
+	handlers map[string]gin.HandlerFunc
+}
+
+func NewRegistry(l logger.Logger) *Registry {
+	return &Registry{
+		keyFns: map[string]KeyFunc{
+			"ip":     ClientIP(),
+			"route":  Route(),
+			"global": nil,
+		},
+		factories: make(map[string]LimiterFactory),
+		l:         l,
+		rules:     atomic.NewPointer(&ruleSet{handlers: map[string]gin.HandlerFunc{}}),
+	}
+}
+
+// RegisterKeyFn 注册 key 的提取方式，默认有 ip、route 和 global
+func (r *Registry) RegisterKeyFn(name string, fn KeyFunc) *Registry {
+	r.keyFns[name] = fn
+	return r
+}
+
+func (r *Registry) RegisterLimiter(name string, factory LimiterFactory) *Registry {
+	r.factories[name] = factory
+	return r
+}
+
+// Update 替换全部规则，规则有错误的时候不会生效。
+// Method 不区分大小写，同一个 Method 和 Path 只能有一条规则
+func (r *Registry) Update(rules []Rule) error {
+	rs := &ruleSet{
+		rules:    make([]Rule, 0, len(rules)),
+		handlers: make(map[string]gin.HandlerFunc, len(rules)),
+	}
+	for _, rule := range rules {
+		// 先统一成大写，匹配路由和限流的 key 用的都是这个
+		rule.Method = strings.ToUpper(rule.Method)
+		key := rule.Method + " " + rule.Path
+		if _, ok := rs.handlers[key]; ok {
+			return fmt.Errorf("rule %s %s: %w", rule.Method, rule.Path, errDuplicateRule)
+		}
+		h, err := r.build(rule)
+		if err != nil {
+			return fmt.Errorf("rule %s %s: %w", rule.Method, rule.Path, err)
+		}
+		rs.rules = append(rs.rules, rule)
+		rs.handlers[key] = h
+	}
+	r.rules.Store(rs)
+	return nil
+}
+
+func (r *Registry) build(rule Rule) (gin.HandlerFunc, error) {
+	if rule.Threshold <= 0 {
+		return nil, errInvalidThreshold
+	}
+	keyFn, ok := r.keyFns[rule.Key]
+	if rule.Key != "" && !ok {
+		return nil, errUnknownKeyFn
+	}
+	factory, ok := r.factories[rule.Limiter]
+	if !ok {
+		return nil, errUnknownLimiter
+	}
+	window, err := time.ParseDuration(rule.Window)
+	if err != nil {
+		return nil, err
+	}
+	// 限流器的构造函数遇到非法参数会 panic，这里先校验，不能让错误的配置把服务搞挂
+	if window <= 0 {
+		return nil, errInvalidWindow
+	}
+
+	return NewMiddlewareBuilder(factory(window, rule.Threshold)).
+		Prefix(fmt.Sprintf("rate_limit:%s:%s", rule.Method, rule.Path)).
+		KeyFn(keyFn).
+		Logger(r.l).
+		Builder(), nil
+}
+
+// LoadFile 从 JSON 文件加载规则，文件内容是 Rule 的数组
+func (r *Registry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var rules []Rule
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return err
+	}
+	return r.Update(rules)
+}
+
+// Watch 每隔 interval 检查一次文件，有修改就重新加载，直到 ctx 被取消
+func (r *Registry) Watch(ctx context.Context, path string, interval time.Duration) {
+	var modTime time.Time
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			r.l.Error("读取限流规则文件失败", logger.Error(err), logger.Any("path", path))
+		case info.ModTime().After(modTime):
+			modTime = info.ModTime()
+			err = r.LoadFile(path)
+			if err != nil {
+				r.l.Error("加载限流规则失败", logger.Error(err), logger.Any("path", path))
+			} else {
+				r.l.Info("加载限流规则成功", logger.Any("path", path))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Rules 当前生效的规则
+func (r *Registry) Rules() []Rule {
+	return r.rules.Load().rules
+}
+
+// Build 按照 ctx.FullPath() 匹配规则，没有匹配的规则就不限流
+func (r *Registry) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		rs := r.rules.Load()
+		h, ok := rs.handlers[ctx.Request.Method+" "+ctx.FullPath()]
+		if !ok {
+			h, ok = rs.handlers[" "+ctx.FullPath()]
+		}
+		if ok {
+			h(ctx)
+		}
+	}
+}
+
+func (r *Registry) RegisterRoutes(server *gin.RouterGroup) {
+	server.GET("/rate_limit/rules", r.ListRules)
+}
+
+func (r *Registry) ListRules(ctx *gin.Context) {
+	ginx.JSON(ctx, http.StatusOK, ginx.Result{Data: r.Rules()})
+}
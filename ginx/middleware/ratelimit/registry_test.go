@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"test/webook/pkg/limiter"
+	"test/webook/pkg/logger"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Update(t *testing.T) {
+	newRegistry := func() *Registry {
+		return NewRegistry(logger.NewNoLogger()).
+			RegisterLimiter("local", func(window time.Duration, threshold int64) limiter.Limiter {
+				return limiter.NewLocalSlideWindowLimiter(window, threshold)
+			})
+	}
+
+	testCases := []struct {
+		name  string
+		rules []Rule
+
+		wantErr   error
+		wantRules []Rule
+	}{
+		{
+			name: "方法统一成大写",
+			rules: []Rule{
+				{Path: "/users/:id", Method: "get", Limiter: "local", Window: "1s", Threshold: 1},
+				{Path: "/users/:id", Limiter: "local", Window: "1s", Threshold: 1},
+			},
+			wantRules: []Rule{
+				{Path: "/users/:id", Method: "GET", Limiter: "local", Window: "1s", Threshold: 1},
+				{Path: "/users/:id", Limiter: "local", Window: "1s", Threshold: 1},
+			},
+		},
+		{
+			name: "大小写不同的重复规则",
+			rules: []Rule{
+				{Path: "/users/:id", Method: "get", Limiter: "local", Window: "1s", Threshold: 1},
+				{Path: "/users/:id", Method: "GET", Limiter: "local", Window: "1s", Threshold: 2},
+			},
+			wantErr: errDuplicateRule,
+		},
+		{
+			name: "阈值不是正数",
+			rules: []Rule{
+				{Path: "/users/:id", Method: "GET", Limiter: "local", Window: "1s", Threshold: 0},
+			},
+			wantErr: errInvalidThreshold,
+		},
+		{
+			name: "窗口不是正数",
+			rules: []Rule{
+				{Path: "/users/:id", Method: "GET", Limiter: "local", Window: "0s", Threshold: 1},
+			},
+			wantErr: errInvalidWindow,
+		},
+		{
+			name: "未知的限流器",
+			rules: []Rule{
+				{Path: "/users/:id", Method: "GET", Limiter: "redis", Window: "1s", Threshold: 1},
+			},
+			wantErr: errUnknownLimiter,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newRegistry()
+			err := r.Update(tc.rules)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				// 规则有错误的时候不生效
+				assert.Empty(t, r.Rules())
+				return
+			}
+			assert.Equal(t, tc.wantRules, r.Rules())
+		})
+	}
+}
+
+func TestRegistry_Build(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := NewRegistry(logger.NewNoLogger()).
+		RegisterLimiter("local", func(window time.Duration, threshold int64) limiter.Limiter {
+			return limiter.NewLocalSlideWindowLimiter(window, threshold)
+		})
+	require.NoError(t, r.Update([]Rule{
+		{Path: "/users/:id", Method: "get", Limiter: "local", Window: "1m", Threshold: 1},
+	}))
+
+	server := gin.New()
+	server.Use(r.Build())
+	server.GET("/users/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	server.POST("/users/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	codes := make([]int, 0, 3)
+	for _, method := range []string{http.MethodGet, http.MethodGet, http.MethodPost} {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(method, "/users/123", nil))
+		codes = append(codes, recorder.Code)
+	}
+	// 小写的 get 规则也能匹配 GET 请求，POST 没有规则不限流
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK}, codes)
+}
@@ -0,0 +1,72 @@
+package recovery
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"runtime/debug"
+	"test/webook/pkg/ginx"
+	"test/webook/pkg/logger"
+	"test/webook/pkg/prometheusx"
+)
+
+type MiddlewareBuilder struct {
+	l       logger.Logger
+	counter *prometheus.CounterVec
+	reg     prometheus.Registerer
+}
+
+func NewMiddlewareBuilder(l logger.Logger) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		l: l,
+	}
+}
+
+// Metrics 按照路由统计 panic 的次数，reg 为空的时候注册到 prometheus.DefaultRegisterer
+func (m *MiddlewareBuilder) Metrics(opts prometheus.CounterOpts, reg prometheus.Registerer) *MiddlewareBuilder {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	m.counter = prometheus.NewCounterVec(opts, []string{"method", "pattern"})
+	m.reg = reg
+	return m
+}
+
+func (m *MiddlewareBuilder) Builder() gin.HandlerFunc {
+	if m.counter != nil {
+		m.counter = prometheusx.MustRegister(m.reg, m.counter)
+	}
+
+	return func(ctx *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			// 标准库用来中断响应的 panic，交给 http.Server 处理
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			m.l.Error("处理请求 panic",
+				logger.Any("panic", fmt.Sprint(r)),
+				logger.Any("stack", string(debug.Stack())),
+				logger.Any("method", ctx.Request.Method),
+				logger.Any("route", ctx.FullPath()),
+				logger.Any("path", ctx.Request.URL.Path),
+				logger.Any("ip", ctx.ClientIP()))
+			if m.counter != nil {
+				m.counter.WithLabelValues(ctx.Request.Method, ctx.FullPath()).Inc()
+			}
+
+			// 已经开始写响应了就没办法再改了
+			if ctx.Writer.Written() {
+				ctx.Abort()
+				return
+			}
+			ginx.AbortWithError(ctx, ginx.ErrInternal)
+		}()
+		ctx.Next()
+	}
+}
@@ -0,0 +1,87 @@
+package recovery
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"test/webook/pkg/ginx"
+	"test/webook/pkg/logger"
+	"testing"
+)
+
+func TestMiddlewareBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testCases := []struct {
+		name    string
+		metrics bool
+
+		wantCount float64
+	}{
+		{
+			name: "不统计",
+		},
+		{
+			name:      "统计 panic 次数",
+			metrics:   true,
+			wantCount: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewMiddlewareBuilder(logger.NewNoLogger())
+			if tc.metrics {
+				b = b.Metrics(prometheus.CounterOpts{Name: "http_panic_total"}, prometheus.NewRegistry())
+			}
+			server := gin.New()
+			server.Use(b.Builder())
+			server.GET("/users/:id", func(ctx *gin.Context) {
+				panic("boom")
+			})
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users/123", nil))
+			assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			var res ginx.Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, ginx.Result{Code: 1, Msg: "系统错误"}, res)
+			if tc.metrics {
+				assert.Equal(t, tc.wantCount, testutil.ToFloat64(b.counter.WithLabelValues(http.MethodGet, "/users/:id")))
+			}
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Written(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(NewMiddlewareBuilder(logger.NewNoLogger()).Builder())
+	server.GET("/", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	// 已经写出去的响应保持原样
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "partial", recorder.Body.String())
+}
+
+func TestMiddlewareBuilder_AbortHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(NewMiddlewareBuilder(logger.NewNoLogger()).Builder())
+	server.GET("/", func(ctx *gin.Context) {
+		panic(http.ErrAbortHandler)
+	})
+
+	// http.ErrAbortHandler 要继续往上抛，交给 http.Server 中断连接
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
@@ -0,0 +1,113 @@
+package timeout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"test/webook/pkg/ginx"
+	"test/webook/pkg/logger"
+	"time"
+)
+
+type MiddlewareBuilder struct {
+	timeout time.Duration
+	// 按照路由单独设置超时时间，key 是 gin 的路由，例如 /users/:id
+	routes map[string]time.Duration
+	l      logger.Logger
+}
+
+// NewMiddlewareBuilder timeout 是默认的超时时间，小于等于 0 表示不限制
+func NewMiddlewareBuilder(timeout time.Duration) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		timeout: timeout,
+		routes:  make(map[string]time.Duration),
+		l:       logger.NewNoLogger(),
+	}
+}
+
+// Route 单独设置某个路由的超时时间，小于等于 0 表示这个路由不限制
+func (m *MiddlewareBuilder) Route(pattern string, timeout time.Duration) *MiddlewareBuilder {
+	m.routes[pattern] = timeout
+	return m
+}
+
+func (m *MiddlewareBuilder) Logger(l logger.Logger) *MiddlewareBuilder {
+	m.l = l
+	return m
+}
+
+// Builder 给 ctx.Request 设置超时时间，handler 用 ctx.Request.Context() 调用下游，下游就能拿到超时时间。
+// handler 在另外一个 goroutine 里面执行，响应先写到缓存里面，超时之后直接返回 504，
+// handler 后面写的响应都会被丢掉。
+// 响应要等 handler 结束才会发出去，Flush 不起作用，SSE 之类的流式响应要用 Route(pattern, 0) 关掉超时
+func (m *MiddlewareBuilder) Builder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		method, route := ctx.Request.Method, ctx.FullPath()
+		timeout, ok := m.routes[route]
+		if !ok {
+			timeout = m.timeout
+		}
+		if timeout <= 0 {
+			ctx.Next()
+			return
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		dst := ctx.Writer
+		tw := newTimeoutWriter(dst)
+		ctx.Writer = tw
+
+		done := make(chan struct{})
+		var panicVal any
+		go func() {
+			defer func() {
+				// panic 交给调用方的 goroutine 处理，这样 recovery 中间件才能拿到
+				panicVal = recover()
+				close(done)
+			}()
+			ctx.Next()
+		}()
+
+		select {
+		case <-done:
+		case <-reqCtx.Done():
+			// 调用方断开连接的时候，写响应也没有意义，等 handler 结束就可以
+			if errors.Is(reqCtx.Err(), context.DeadlineExceeded) {
+				tw.timeout()
+				m.l.Warn("请求超时",
+					logger.Any("method", method),
+					logger.Any("route", route),
+					logger.Any("timeout", timeout.String()))
+				writeTimeout(dst)
+			}
+			// gin.Context 会被复用，一定要等 handler 结束
+			<-done
+		}
+
+		ctx.Writer = dst
+		if panicVal != nil {
+			panic(panicVal)
+		}
+		if tw.timedOut {
+			// handler 结束之后才设置错误码，不然会被 handler 后面写的 Result 覆盖
+			_, res := ginx.ToResult(ginx.ErrTimeout)
+			ctx.Set(ginx.ResultCodeKey, res.Code)
+			ctx.Abort()
+			return
+		}
+		tw.flush()
+	}
+}
+
+func writeTimeout(dst gin.ResponseWriter) {
+	status, res := ginx.ToResult(ginx.ErrTimeout)
+	dst.Header().Set("Content-Type", "application/json; charset=utf-8")
+	dst.WriteHeader(status)
+	_ = json.NewEncoder(dst).Encode(res)
+	// handler 可能还要执行一段时间，先把响应发出去
+	dst.Flush()
+}
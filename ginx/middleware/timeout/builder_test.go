@@ -0,0 +1,150 @@
+package timeout
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"test/webook/pkg/ginx"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(NewMiddlewareBuilder(50*time.Millisecond).
+		Route("/slow/unlimited", 0).
+		Route("/fast/short", time.Millisecond).
+		Builder())
+
+	server.GET("/fast", func(ctx *gin.Context) {
+		_, ok := ctx.Request.Context().Deadline()
+		ctx.Header("X-Deadline", "false")
+		if ok {
+			ctx.Header("X-Deadline", "true")
+		}
+		ctx.JSON(http.StatusOK, ginx.Result{Msg: "OK"})
+	})
+	server.GET("/slow", func(ctx *gin.Context) {
+		<-ctx.Request.Context().Done()
+		// 超时之后写的响应会被丢掉
+		ctx.Header("X-Late", "true")
+		ctx.JSON(http.StatusOK, ginx.Result{Msg: "late"})
+	})
+	server.GET("/slow/unlimited", func(ctx *gin.Context) {
+		time.Sleep(80 * time.Millisecond)
+		ctx.JSON(http.StatusOK, ginx.Result{Msg: "OK"})
+	})
+	server.GET("/fast/short", func(ctx *gin.Context) {
+		time.Sleep(20 * time.Millisecond)
+		ctx.JSON(http.StatusOK, ginx.Result{Msg: "OK"})
+	})
+
+	testCases := []struct {
+		name string
+		path string
+
+		wantCode   int
+		wantResult ginx.Result
+		wantHeader http.Header
+	}{
+		{
+			name:       "没有超时",
+			path:       "/fast",
+			wantCode:   http.StatusOK,
+			wantResult: ginx.Result{Msg: "OK"},
+			wantHeader: http.Header{"X-Deadline": []string{"true"}},
+		},
+		{
+			name:       "超时",
+			path:       "/slow",
+			wantCode:   http.StatusGatewayTimeout,
+			wantResult: ginx.Result{Code: 4, Msg: "请求超时"},
+		},
+		{
+			name:       "路由不限制超时",
+			path:       "/slow/unlimited",
+			wantCode:   http.StatusOK,
+			wantResult: ginx.Result{Msg: "OK"},
+		},
+		{
+			name:       "路由单独设置超时时间",
+			path:       "/fast/short",
+			wantCode:   http.StatusGatewayTimeout,
+			wantResult: ginx.Result{Code: 4, Msg: "请求超时"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			var res ginx.Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantResult, res)
+			assert.Empty(t, recorder.Header().Get("X-Late"))
+			for k := range tc.wantHeader {
+				assert.Equal(t, tc.wantHeader.Get(k), recorder.Header().Get(k))
+			}
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Panic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	var recovered any
+	server.Use(func(ctx *gin.Context) {
+		defer func() {
+			recovered = recover()
+			if recovered != nil {
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		ctx.Next()
+	})
+	server.Use(NewMiddlewareBuilder(time.Second).Builder())
+	server.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/panic", nil)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	// panic 要交给外层的中间件处理
+	assert.Equal(t, "boom", recovered)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
+
+func TestMiddlewareBuilder_LateResult(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	var code any
+	server.Use(func(ctx *gin.Context) {
+		ctx.Next()
+		code, _ = ctx.Get(ginx.ResultCodeKey)
+	})
+	server.Use(NewMiddlewareBuilder(10 * time.Millisecond).Builder())
+	server.GET("/slow", func(ctx *gin.Context) {
+		<-ctx.Request.Context().Done()
+		// 超时之后才写 Result，不能覆盖超时的错误码
+		time.Sleep(10 * time.Millisecond)
+		ginx.JSON(ctx, http.StatusOK, ginx.Result{Msg: "late"})
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/slow", nil)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusGatewayTimeout, recorder.Code)
+	_, res := ginx.ToResult(ginx.ErrTimeout)
+	assert.Equal(t, res.Code, code)
+}
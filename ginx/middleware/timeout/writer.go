@@ -0,0 +1,114 @@
+package timeout
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sync"
+)
+
+// timeoutWriter 先把 handler 的响应写到缓存里面，handler 正常结束才写到真正的响应里面。
+// 超时之后的写入全部丢掉，避免和超时响应交错在一起
+type timeoutWriter struct {
+	gin.ResponseWriter
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	written  bool
+	timedOut bool
+}
+
+func newTimeoutWriter(w gin.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{
+		ResponseWriter: w,
+		// 前面的中间件可能已经设置了一些响应头
+		header: w.Header().Clone(),
+		status: http.StatusOK,
+	}
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.written || code <= 0 {
+		return
+	}
+	w.status = code
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = true
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written
+}
+
+// Flush 缓存的响应要等 handler 结束才能发出去，所以这里什么也不做
+func (w *timeoutWriter) Flush() {}
+
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timedOut = true
+}
+
+// flush 把缓存的响应写到真正的响应里面，handler 结束之后调用
+func (w *timeoutWriter) flush() {
+	dst := w.ResponseWriter.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.written {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
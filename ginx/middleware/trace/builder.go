@@ -0,0 +1,78 @@
+package trace
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+const instrumentationName = "test/webook/pkg/ginx/middleware/trace"
+
+type MiddlewareBuilder struct {
+	tp         trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// NewMiddlewareBuilder 默认使用全局的 TracerProvider 和 propagator，
+// 也就是 otel.SetTracerProvider 和 otel.SetTextMapPropagator 设置的
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{}
+}
+
+func (m *MiddlewareBuilder) TracerProvider(tp trace.TracerProvider) *MiddlewareBuilder {
+	m.tp = tp
+	return m
+}
+
+func (m *MiddlewareBuilder) Propagator(p propagation.TextMapPropagator) *MiddlewareBuilder {
+	m.propagator = p
+	return m
+}
+
+func (m *MiddlewareBuilder) Builder() gin.HandlerFunc {
+	tp := m.tp
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	propagator := m.propagator
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+	tracer := tp.Tracer(instrumentationName)
+
+	return func(ctx *gin.Context) {
+		// 接上调用方的 trace，没有的话就是一条新的 trace
+		reqCtx := propagator.Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+		reqCtx, span := tracer.Start(reqCtx, "HTTP "+ctx.Request.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", ctx.Request.Method),
+				attribute.String("url.path", ctx.Request.URL.Path),
+				attribute.String("client.address", ctx.ClientIP()),
+			))
+		defer span.End()
+
+		// 后面的 handler 用 ctx.Request.Context() 调用下游，下游的 span 就会挂在这个 span 下面
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		ctx.Next()
+
+		// 路由要在 Next 之后才能拿到，没有匹配上的路由保留原来的名字
+		if route := ctx.FullPath(); route != "" {
+			span.SetName(fmt.Sprintf("%s %s", ctx.Request.Method, route))
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		status := ctx.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		for _, err := range ctx.Errors {
+			span.RecordError(err.Err)
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package trace
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	server := gin.New()
+	server.Use(NewMiddlewareBuilder().
+		TracerProvider(tp).
+		Propagator(propagation.TraceContext{}).
+		Builder())
+	var handlerSpan trace.SpanContext
+	server.GET("/users/:id", func(ctx *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(ctx.Request.Context())
+		ctx.Status(http.StatusOK)
+	})
+	server.GET("/error", func(ctx *gin.Context) {
+		ctx.Status(http.StatusInternalServerError)
+	})
+
+	testCases := []struct {
+		name   string
+		path   string
+		header http.Header
+
+		wantName   string
+		wantStatus codes.Code
+		wantParent string
+	}{
+		{
+			name:       "新的 trace",
+			path:       "/users/123",
+			wantName:   "GET /users/:id",
+			wantStatus: codes.Unset,
+		},
+		{
+			name: "接上调用方的 trace",
+			path: "/users/123",
+			header: http.Header{
+				"Traceparent": []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			},
+			wantName:   "GET /users/:id",
+			wantStatus: codes.Unset,
+			wantParent: "00f067aa0ba902b7",
+		},
+		{
+			name:       "5xx 标记为错误",
+			path:       "/error",
+			wantName:   "GET /error",
+			wantStatus: codes.Error,
+		},
+		{
+			name:       "没有匹配的路由",
+			path:       "/not_found",
+			wantName:   "HTTP GET",
+			wantStatus: codes.Unset,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter.Reset()
+			handlerSpan = trace.SpanContext{}
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			server.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, tc.wantName, span.Name)
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+			assert.Equal(t, tc.wantStatus, span.Status.Code)
+			if handlerSpan.IsValid() {
+				assert.Equal(t, span.SpanContext.SpanID(), handlerSpan.SpanID())
+			}
+			if tc.wantParent != "" {
+				assert.Equal(t, tc.wantParent, span.Parent.SpanID().String())
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+			} else {
+				assert.False(t, span.Parent.IsValid())
+			}
+			assert.Contains(t, span.Attributes, attribute.String("url.path", tc.path))
+		})
+	}
+}
@@ -1,12 +1,128 @@
 package ginx
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/atomic"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
 
 type Server struct {
 	Server *gin.Engine
 	Addr   string
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// 收到退出信号之后先标记为未就绪，等待 ShutdownDelay 让负载均衡摘掉流量，再开始关闭
+	ShutdownDelay time.Duration
+	// 等待处理中的请求结束的最长时间
+	ShutdownTimeout time.Duration
+
+	lock  sync.Mutex
+	srv   *http.Server
+	ready atomic.Bool
+	// Shutdown 比 Start 先调用的时候，Start 不再启动服务
+	closed bool
 }
 
+// Start 启动服务，直到 Shutdown 被调用才返回。
+// 端口监听成功之后才标记为就绪
 func (s *Server) Start() error {
-	return s.Server.Run(s.Addr)
+	addr := s.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return l.Close()
+	}
+	s.srv = &http.Server{
+		Addr:         s.Addr,
+		Handler:      s.Server,
+		ReadTimeout:  s.ReadTimeout,
+		WriteTimeout: s.WriteTimeout,
+		IdleTimeout:  s.IdleTimeout,
+	}
+	srv := s.srv
+	s.ready.Store(true)
+	s.lock.Unlock()
+
+	err = srv.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown 不再接收新的请求，等待处理中的请求结束，直到 ctx 过期
+func (s *Server) Shutdown(ctx context.Context) error {
+	srv := s.close()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+// Run 启动服务，收到 SIGINT 或者 SIGTERM 之后优雅退出
+func (s *Server) Run() error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start()
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	select {
+	case err := <-errCh:
+		return err
+	case <-quit:
+	}
+
+	s.close()
+	time.Sleep(s.ShutdownDelay)
+	ctx := context.Background()
+	if s.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.ShutdownTimeout)
+		defer cancel()
+	}
+	err := s.Shutdown(ctx)
+	if err != nil {
+		return err
+	}
+	return <-errCh
+}
+
+// close 标记为未就绪，并且不再允许 Start 启动服务。
+// 和 Start 里面标记就绪在同一把锁下面，避免 Start 在这之后又标记为就绪
+func (s *Server) close() *http.Server {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ready.Store(false)
+	s.closed = true
+	return s.srv
+}
+
+// Ready 就绪检查，服务启动之后返回 200，开始退出之后返回 503
+func (s *Server) Ready(ctx *gin.Context) {
+	if s.ready.Load() {
+		ctx.Status(http.StatusOK)
+		return
+	}
+	ctx.Status(http.StatusServiceUnavailable)
 }
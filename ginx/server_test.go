@@ -0,0 +1,58 @@
+package ginx
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer_Ready(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{Server: gin.New(), Addr: "127.0.0.1:0"}
+	assert.Equal(t, http.StatusServiceUnavailable, ready(s))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start()
+	}()
+	require.Eventually(t, func() bool {
+		return ready(s) == http.StatusOK
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, s.Shutdown(context.Background()))
+	assert.Equal(t, http.StatusServiceUnavailable, ready(s))
+	require.NoError(t, <-errCh)
+}
+
+func TestServer_ListenFailed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	s := &Server{Server: gin.New(), Addr: l.Addr().String()}
+	// 端口被占用，不能标记为就绪
+	assert.Error(t, s.Start())
+	assert.Equal(t, http.StatusServiceUnavailable, ready(s))
+}
+
+func TestServer_ShutdownBeforeStart(t *testing.T) {
+	s := &Server{Server: gin.New(), Addr: "127.0.0.1:0"}
+	require.NoError(t, s.Shutdown(context.Background()))
+	// 已经关闭了，不再启动
+	require.NoError(t, s.Start())
+	assert.Equal(t, http.StatusServiceUnavailable, ready(s))
+}
+
+func ready(s *Server) int {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	s.Ready(ctx)
+	ctx.Writer.WriteHeaderNow()
+	return recorder.Code
+}
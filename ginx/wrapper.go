@@ -0,0 +1,103 @@
+package ginx
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"test/webook/pkg/logger"
+)
+
+// L 包装函数记录错误用的日志，启动的时候替换成真正的实现
+var L logger.Logger = logger.NewNoLogger()
+
+// ClaimsKey 登录校验的中间件把 claims 放到 gin.Context 的这个 key 下面
+const ClaimsKey = "claims"
+
+// Wrap 业务逻辑返回 Result，出错的时候记录日志
+func Wrap(fn func(ctx *gin.Context) (Result, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		res, err := fn(ctx)
+		handleResult(ctx, res, err)
+	}
+}
+
+// WrapBody 绑定请求参数到 Req 上
+func WrapBody[Req any](fn func(ctx *gin.Context, req Req) (Result, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		req, ok := bind[Req](ctx)
+		if !ok {
+			return
+		}
+		res, err := fn(ctx, req)
+		handleResult(ctx, res, err)
+	}
+}
+
+// WrapClaims 从 gin.Context 中取出登录校验中间件放进去的 Claims
+func WrapClaims[Claims any](fn func(ctx *gin.Context, uc Claims) (Result, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		uc, ok := claims[Claims](ctx)
+		if !ok {
+			return
+		}
+		res, err := fn(ctx, uc)
+		handleResult(ctx, res, err)
+	}
+}
+
+func WrapBodyAndClaims[Req any, Claims any](fn func(ctx *gin.Context, req Req, uc Claims) (Result, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		req, ok := bind[Req](ctx)
+		if !ok {
+			return
+		}
+		uc, ok := claims[Claims](ctx)
+		if !ok {
+			return
+		}
+		res, err := fn(ctx, req, uc)
+		handleResult(ctx, res, err)
+	}
+}
+
+func bind[Req any](ctx *gin.Context) (Req, bool) {
+	var req Req
+	err := ctx.ShouldBind(&req)
+	if err != nil {
+		L.Warn("绑定参数失败", logger.Error(err), logger.Any("route", ctx.FullPath()))
+		AbortWithError(ctx, ErrInvalidParam)
+		return req, false
+	}
+	return req, true
+}
+
+func claims[Claims any](ctx *gin.Context) (Claims, bool) {
+	val, _ := ctx.Get(ClaimsKey)
+	uc, ok := val.(Claims)
+	if !ok {
+		L.Error("获取 claims 失败", logger.Any("route", ctx.FullPath()))
+		AbortWithError(ctx, ErrUnauthorized)
+	}
+	return uc, ok
+}
+
+// handleResult 返回的是 *Error 的时候按照错误码响应，内部原因只记录日志。
+// 其它错误如果业务逻辑没有给出 Result，按照系统错误处理
+func handleResult(ctx *gin.Context, res Result, err error) {
+	if err == nil {
+		JSON(ctx, http.StatusOK, res)
+		return
+	}
+
+	L.Error("处理业务逻辑出错",
+		logger.Error(err),
+		logger.Any("route", ctx.FullPath()),
+		logger.Any("method", ctx.Request.Method))
+	var e *Error
+	if errors.As(err, &e) || (res.Code == 0 && res.Msg == "" && res.Data == nil) {
+		status, r := ToResult(err)
+		JSON(ctx, status, r)
+		return
+	}
+	JSON(ctx, http.StatusOK, res)
+}
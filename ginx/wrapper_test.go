@@ -0,0 +1,148 @@
+package ginx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type wrapperReq struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type wrapperClaims struct {
+	Uid int64
+}
+
+func TestWrapper(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	errUserNotFound := &Error{Code: 10001, Msg: "用户不存在"}
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		if ctx.GetHeader("X-Uid") != "" {
+			ctx.Set(ClaimsKey, wrapperClaims{Uid: 123})
+		}
+	})
+	server.POST("/body", WrapBody(func(ctx *gin.Context, req wrapperReq) (Result, error) {
+		return Result{Data: req.Name}, nil
+	}))
+	server.GET("/claims", WrapClaims(func(ctx *gin.Context, uc wrapperClaims) (Result, error) {
+		return Result{Data: uc.Uid}, nil
+	}))
+	server.POST("/body_claims", WrapBodyAndClaims(func(ctx *gin.Context, req wrapperReq, uc wrapperClaims) (Result, error) {
+		return Result{Data: req.Name}, nil
+	}))
+	server.GET("/biz_err", Wrap(func(ctx *gin.Context) (Result, error) {
+		return Result{}, errUserNotFound.Wrap(errors.New("record not found")).WithStatus(http.StatusNotFound)
+	}))
+	server.GET("/err", Wrap(func(ctx *gin.Context) (Result, error) {
+		return Result{}, errors.New("db error")
+	}))
+	server.GET("/err_with_result", Wrap(func(ctx *gin.Context) (Result, error) {
+		return Result{Code: 5, Msg: "稍后再试"}, errors.New("db error")
+	}))
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		login  bool
+
+		wantCode   int
+		wantResult Result
+	}{
+		{
+			name:       "绑定参数",
+			method:     http.MethodPost,
+			path:       "/body",
+			body:       `{"name":"Tom"}`,
+			wantCode:   http.StatusOK,
+			wantResult: Result{Data: "Tom"},
+		},
+		{
+			name:       "绑定参数失败",
+			method:     http.MethodPost,
+			path:       "/body",
+			body:       `{"name":`,
+			wantCode:   http.StatusBadRequest,
+			wantResult: Result{Code: 2, Msg: "参数错误"},
+		},
+		{
+			name:       "缺少必填参数",
+			method:     http.MethodPost,
+			path:       "/body",
+			body:       `{}`,
+			wantCode:   http.StatusBadRequest,
+			wantResult: Result{Code: 2, Msg: "参数错误"},
+		},
+		{
+			name:       "取出 claims",
+			method:     http.MethodGet,
+			path:       "/claims",
+			login:      true,
+			wantCode:   http.StatusOK,
+			wantResult: Result{Data: float64(123)},
+		},
+		{
+			name:       "没有 claims",
+			method:     http.MethodGet,
+			path:       "/claims",
+			wantCode:   http.StatusUnauthorized,
+			wantResult: Result{Code: 3, Msg: "未登录"},
+		},
+		{
+			name:       "先绑定参数再取 claims",
+			method:     http.MethodPost,
+			path:       "/body_claims",
+			body:       `{"name":"Tom"}`,
+			wantCode:   http.StatusUnauthorized,
+			wantResult: Result{Code: 3, Msg: "未登录"},
+		},
+		{
+			name:       "业务错误不返回内部原因",
+			method:     http.MethodGet,
+			path:       "/biz_err",
+			wantCode:   http.StatusNotFound,
+			wantResult: Result{Code: 10001, Msg: "用户不存在"},
+		},
+		{
+			name:       "其它错误按照系统错误处理",
+			method:     http.MethodGet,
+			path:       "/err",
+			wantCode:   http.StatusInternalServerError,
+			wantResult: Result{Code: 1, Msg: "系统错误"},
+		},
+		{
+			name:       "出错的时候使用业务逻辑给出的 Result",
+			method:     http.MethodGet,
+			path:       "/err_with_result",
+			wantCode:   http.StatusOK,
+			wantResult: Result{Code: 5, Msg: "稍后再试"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			if tc.login {
+				req.Header.Set("X-Uid", "123")
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantResult, res)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package callbacks
+
+import (
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const instrumentationName = "test/webook/pkg/gormx/callbacks"
+
+// TracePlugin 给每条 SQL 创建一个 span，span 挂在 db.WithContext 传进来的 ctx 下面。
+// 使用 db.Use(callbacks.NewTracePlugin()) 注册
+type TracePlugin struct {
+	tp trace.TracerProvider
+	// 是否在 span 里面记录 SQL，SQL 里面的参数可能有敏感数据
+	withStatement bool
+}
+
+func NewTracePlugin() *TracePlugin {
+	return &TracePlugin{withStatement: true}
+}
+
+func (p *TracePlugin) TracerProvider(tp trace.TracerProvider) *TracePlugin {
+	p.tp = tp
+	return p
+}
+
+func (p *TracePlugin) WithStatement(ok bool) *TracePlugin {
+	p.withStatement = ok
+	return p
+}
+
+func (p *TracePlugin) Name() string {
+	return "trace"
+}
+
+func (p *TracePlugin) Initialize(db *gorm.DB) error {
+	tp := p.tp
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	tracer := tp.Tracer(instrumentationName)
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("*").Register("trace:before_create", p.before(tracer, "create")),
+		cb.Create().After("*").Register("trace:after_create", p.after),
+		cb.Query().Before("*").Register("trace:before_query", p.before(tracer, "query")),
+		cb.Query().After("*").Register("trace:after_query", p.after),
+		cb.Update().Before("*").Register("trace:before_update", p.before(tracer, "update")),
+		cb.Update().After("*").Register("trace:after_update", p.after),
+		cb.Delete().Before("*").Register("trace:before_delete", p.before(tracer, "delete")),
+		cb.Delete().After("*").Register("trace:after_delete", p.after),
+		cb.Row().Before("*").Register("trace:before_row", p.before(tracer, "row")),
+		cb.Row().After("*").Register("trace:after_row", p.after),
+		cb.Raw().Before("*").Register("trace:before_raw", p.before(tracer, "raw")),
+		cb.Raw().After("*").Register("trace:after_raw", p.after),
+	)
+}
+
+func (p *TracePlugin) before(tracer trace.Tracer, typ string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		// 表名要等 gorm 解析完 model 才有，这里先用操作类型，after 里面再补上
+		ctx, _ := tracer.Start(db.Statement.Context, "gorm:"+typ,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", db.Dialector.Name()),
+				attribute.String("db.operation.name", typ),
+			))
+		db.Statement.Context = ctx
+	}
+}
+
+func (p *TracePlugin) after(db *gorm.DB) {
+	span := trace.SpanFromContext(db.Statement.Context)
+	if !span.IsRecording() {
+		return
+	}
+	defer span.End()
+
+	if table := db.Statement.Table; table != "" {
+		span.SetAttributes(attribute.String("db.collection.name", table))
+	}
+	if p.withStatement {
+		span.SetAttributes(attribute.String("db.query.text", db.Statement.SQL.String()))
+	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", db.RowsAffected))
+	// 查不到数据是业务上的正常情况，不算错误
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -0,0 +1,108 @@
+package callbacks
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"testing"
+)
+
+type User struct {
+	Id   int64
+	Name string
+}
+
+func TestTracePlugin(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	// DryRun 只生成 SQL，不开事务，不需要真的连上数据库
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "root:root@tcp(localhost:13316)/webook",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(NewTracePlugin().TracerProvider(tp)))
+
+	testCases := []struct {
+		name    string
+		exec    func(db *gorm.DB) error
+		withSQL bool
+
+		wantName      string
+		wantOperation string
+		wantStatus    codes.Code
+	}{
+		{
+			name: "查询",
+			exec: func(db *gorm.DB) error {
+				var u User
+				return db.Where("id = ?", 1).Find(&u).Error
+			},
+			withSQL:       true,
+			wantName:      "gorm:query",
+			wantOperation: "query",
+			wantStatus:    codes.Unset,
+		},
+		{
+			name: "新建",
+			exec: func(db *gorm.DB) error {
+				return db.Create(&User{Name: "Tom"}).Error
+			},
+			wantName:      "gorm:create",
+			wantOperation: "create",
+			wantStatus:    codes.Unset,
+		},
+		{
+			name: "出错",
+			exec: func(db *gorm.DB) error {
+				// 没有 WHERE 条件的删除会被 gorm 拒绝
+				return db.Delete(&User{}).Error
+			},
+			wantName:      "gorm:delete",
+			wantOperation: "delete",
+			wantStatus:    codes.Error,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter.Reset()
+			ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+			err := tc.exec(db.WithContext(ctx))
+			parent.End()
+			if tc.wantStatus == codes.Error {
+				assert.ErrorIs(t, err, gorm.ErrMissingWhereClause)
+			} else {
+				require.NoError(t, err)
+			}
+
+			spans := exporter.GetSpans()
+			// 还有一个 parent
+			require.Len(t, spans, 2)
+			span := spans[0]
+			assert.Equal(t, tc.wantName, span.Name)
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+			assert.Equal(t, tc.wantStatus, span.Status.Code)
+			assert.Contains(t, span.Attributes, attribute.String("db.system", "mysql"))
+			assert.Contains(t, span.Attributes, attribute.String("db.operation.name", tc.wantOperation))
+			assert.Contains(t, span.Attributes, attribute.String("db.collection.name", "users"))
+			if tc.withSQL {
+				assert.Contains(t, span.Attributes,
+					attribute.String("db.query.text", "SELECT * FROM `users` WHERE id = ?"))
+			}
+		})
+	}
+}
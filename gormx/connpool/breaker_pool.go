@@ -0,0 +1,159 @@
+package connpool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gorm.io/gorm"
+	"test/webook/pkg/breaker"
+)
+
+// BreakerPool 在熔断器的保护下访问数据库，数据库出问题的时候快速失败
+type BreakerPool struct {
+	pool    gorm.ConnPool
+	breaker *breaker.Breaker
+	// 判断错误是否要计入熔断，默认查不到数据、请求取消不算
+	isFailure func(err error) bool
+}
+
+func NewBreakerPool(db *gorm.DB, b *breaker.Breaker) *BreakerPool {
+	return &BreakerPool{
+		pool:      db.ConnPool,
+		breaker:   b,
+		isFailure: isFailure,
+	}
+}
+
+func (b *BreakerPool) IsFailure(fn func(err error) bool) *BreakerPool {
+	b.isFailure = fn
+	return b
+}
+
+// BeginTx 开启事务也要经过熔断器，返回的事务里面的语句同样受熔断器保护
+func (b *BreakerPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	var tx gorm.ConnPool
+	err := b.do(func() error {
+		var err error
+		switch pool := b.pool.(type) {
+		case gorm.TxBeginner:
+			tx, err = pool.BeginTx(ctx, opts)
+		case gorm.ConnPoolBeginner:
+			tx, err = pool.BeginTx(ctx, opts)
+		default:
+			err = gorm.ErrInvalidTransaction
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BreakerPoolTx{tx: tx, b: b}, nil
+}
+
+func (b *BreakerPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return b.prepare(b.pool, ctx, query)
+}
+
+func (b *BreakerPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return b.exec(b.pool, ctx, query, args...)
+}
+
+func (b *BreakerPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return b.query(b.pool, ctx, query, args...)
+}
+
+// QueryRowContext *sql.Row 没办法在外部构造错误，所以不经过熔断器
+func (b *BreakerPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return b.pool.QueryRowContext(ctx, query, args...)
+}
+
+func (b *BreakerPool) prepare(pool gorm.ConnPool, ctx context.Context, query string) (*sql.Stmt, error) {
+	var stmt *sql.Stmt
+	err := b.do(func() error {
+		var err error
+		stmt, err = pool.PrepareContext(ctx, query)
+		return err
+	})
+	return stmt, err
+}
+
+func (b *BreakerPool) exec(pool gorm.ConnPool, ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := b.do(func() error {
+		var err error
+		result, err = pool.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+func (b *BreakerPool) query(pool gorm.ConnPool, ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := b.do(func() error {
+		var err error
+		rows, err = pool.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (b *BreakerPool) do(fn func() error) error {
+	done, err := b.breaker.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if b.isFailure(err) {
+		done(err)
+	} else {
+		done(nil)
+	}
+	return err
+}
+
+func isFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, sql.ErrNoRows) &&
+		!errors.Is(err, gorm.ErrRecordNotFound) &&
+		!errors.Is(err, context.Canceled)
+}
+
+// BreakerPoolTx BreakerPool 开启的事务，和 BreakerPool 共用一个熔断器
+type BreakerPoolTx struct {
+	tx gorm.ConnPool
+	b  *BreakerPool
+}
+
+func (t *BreakerPoolTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.b.prepare(t.tx, ctx, query)
+}
+
+func (t *BreakerPoolTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.b.exec(t.tx, ctx, query, args...)
+}
+
+func (t *BreakerPoolTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.b.query(t.tx, ctx, query, args...)
+}
+
+func (t *BreakerPoolTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+// Commit 不经过熔断器，事务中途熔断的话拒绝提交会导致连接一直被占着
+func (t *BreakerPoolTx) Commit() error {
+	tx, ok := t.tx.(gorm.TxCommitter)
+	if !ok {
+		return gorm.ErrInvalidTransaction
+	}
+	return tx.Commit()
+}
+
+// Rollback 同样不经过熔断器，熔断的时候也要尽量回滚，释放连接
+func (t *BreakerPoolTx) Rollback() error {
+	tx, ok := t.tx.(gorm.TxCommitter)
+	if !ok {
+		return gorm.ErrInvalidTransaction
+	}
+	return tx.Rollback()
+}
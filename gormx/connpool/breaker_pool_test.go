@@ -0,0 +1,163 @@
+package connpool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"test/webook/pkg/breaker"
+	"test/webook/pkg/logger"
+	"testing"
+	"time"
+)
+
+var errMockDB = errors.New("mock db error")
+
+func newTestBreakerPool(pool gorm.ConnPool) *BreakerPool {
+	b := breaker.NewBreaker("test", logger.NewNoLogger()).
+		SetMinRequests(2).
+		SetErrorRate(0.5).
+		SetOpenTimeout(time.Minute)
+	return NewBreakerPool(&gorm.DB{Config: &gorm.Config{ConnPool: pool}}, b)
+}
+
+func TestBreakerPool(t *testing.T) {
+	testCases := []struct {
+		name string
+		errs []error
+
+		wantState string
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "成功",
+			errs:      []error{nil, nil, nil},
+			wantState: breaker.StateClosed,
+			wantCalls: 3,
+		},
+		{
+			name:      "查不到数据不算失败",
+			errs:      []error{sql.ErrNoRows, sql.ErrNoRows, sql.ErrNoRows},
+			wantState: breaker.StateClosed,
+			wantCalls: 3,
+			wantErr:   sql.ErrNoRows,
+		},
+		{
+			name:      "熔断之后不再访问数据库",
+			errs:      []error{errMockDB, errMockDB, nil},
+			wantState: breaker.StateOpen,
+			wantCalls: 2,
+			wantErr:   breaker.ErrOpen,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := &mockConnPool{errs: tc.errs}
+			p := newTestBreakerPool(pool)
+			var err error
+			for range tc.errs {
+				_, err = p.ExecContext(context.Background(), "UPDATE users SET name = ?", "Tom")
+			}
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantCalls, pool.calls)
+			assert.Equal(t, tc.wantState, p.breaker.State())
+		})
+	}
+}
+
+func TestBreakerPoolTx(t *testing.T) {
+	testCases := []struct {
+		name   string
+		finish func(tx *BreakerPoolTx) error
+
+		wantCommitted  bool
+		wantRolledBack bool
+	}{
+		{
+			name:          "熔断之后也能提交",
+			finish:        (*BreakerPoolTx).Commit,
+			wantCommitted: true,
+		},
+		{
+			name:           "熔断之后也能回滚",
+			finish:         (*BreakerPoolTx).Rollback,
+			wantRolledBack: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := &mockConnPool{errs: []error{errMockDB}}
+			p := newTestBreakerPool(pool)
+			ctx := context.Background()
+			conn, err := p.BeginTx(ctx, nil)
+			require.NoError(t, err)
+			tx := conn.(*BreakerPoolTx)
+
+			// 事务里面的语句同样受熔断器保护，加上 BeginTx 一共两个请求，一半失败
+			_, err = tx.ExecContext(ctx, "UPDATE users SET name = ?", "Tom")
+			assert.Equal(t, errMockDB, err)
+			assert.Equal(t, breaker.StateOpen, p.breaker.State())
+			_, err = tx.ExecContext(ctx, "UPDATE users SET name = ?", "Tom")
+			assert.Equal(t, breaker.ErrOpen, err)
+			_, err = p.BeginTx(ctx, nil)
+			assert.Equal(t, breaker.ErrOpen, err)
+
+			require.NoError(t, tc.finish(tx))
+			assert.Equal(t, tc.wantCommitted, pool.tx.committed)
+			assert.Equal(t, tc.wantRolledBack, pool.tx.rolledBack)
+		})
+	}
+}
+
+// mockConnPool 按顺序返回 errs 里面的错误，事务和它共用 errs
+type mockConnPool struct {
+	errs  []error
+	calls int
+	tx    *mockTx
+}
+
+func (m *mockConnPool) next() error {
+	err := m.errs[m.calls]
+	m.calls++
+	return err
+}
+
+func (m *mockConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	m.tx = &mockTx{mockConnPool: m}
+	return m.tx, nil
+}
+
+func (m *mockConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, m.next()
+}
+
+func (m *mockConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, m.next()
+}
+
+func (m *mockConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, m.next()
+}
+
+func (m *mockConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+type mockTx struct {
+	*mockConnPool
+	committed  bool
+	rolledBack bool
+}
+
+func (m *mockTx) Commit() error {
+	m.committed = true
+	return nil
+}
+
+func (m *mockTx) Rollback() error {
+	m.rolledBack = true
+	return nil
+}
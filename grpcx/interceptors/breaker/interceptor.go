@@ -0,0 +1,63 @@
+package breaker
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"test/webook/pkg/breaker"
+)
+
+// InterceptorBuilder 客户端熔断，每个方法一个熔断器
+type InterceptorBuilder struct {
+	newBreaker func(fullMethod string) *breaker.Breaker
+	breakers   sync.Map
+	// 判断调用是否失败，默认只有服务端不可用之类的错误才算失败，业务错误不算
+	isFailure func(err error) bool
+}
+
+func NewInterceptorBuilder(newBreaker func(fullMethod string) *breaker.Breaker) *InterceptorBuilder {
+	return &InterceptorBuilder{
+		newBreaker: newBreaker,
+		isFailure:  isFailure,
+	}
+}
+
+func (b *InterceptorBuilder) IsFailure(fn func(err error) bool) *InterceptorBuilder {
+	b.isFailure = fn
+	return b
+}
+
+func (b *InterceptorBuilder) BuildUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		done, err := b.breaker(method).Allow()
+		if err != nil {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		if b.isFailure(err) {
+			done(err)
+		} else {
+			done(nil)
+		}
+		return err
+	}
+}
+
+func (b *InterceptorBuilder) breaker(fullMethod string) *breaker.Breaker {
+	if val, ok := b.breakers.Load(fullMethod); ok {
+		return val.(*breaker.Breaker)
+	}
+	val, _ := b.breakers.LoadOrStore(fullMethod, b.newBreaker(fullMethod))
+	return val.(*breaker.Breaker)
+}
+
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,68 @@
+package breaker
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"test/webook/pkg/breaker"
+	"test/webook/pkg/logger"
+	"testing"
+	"time"
+)
+
+func TestInterceptorBuilder(t *testing.T) {
+	testCases := []struct {
+		name string
+		code codes.Code
+
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{
+			name:      "成功",
+			code:      codes.OK,
+			wantCalls: 3,
+			wantCode:  codes.OK,
+		},
+		{
+			name:      "业务错误不算失败",
+			code:      codes.NotFound,
+			wantCalls: 3,
+			wantCode:  codes.NotFound,
+		},
+		{
+			name:      "服务端不可用就熔断",
+			code:      codes.Unavailable,
+			wantCalls: 2,
+			wantCode:  codes.Unavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			interceptor := NewInterceptorBuilder(func(fullMethod string) *breaker.Breaker {
+				return breaker.NewBreaker(fullMethod, logger.NewNoLogger()).
+					SetMinRequests(2).
+					SetOpenTimeout(time.Minute)
+			}).BuildUnaryClientInterceptor()
+			calls := 0
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				calls++
+				return status.Error(tc.code, "mock")
+			}
+
+			var err error
+			for i := 0; i < 3; i++ {
+				err = interceptor(context.Background(), "/user.UserService/Profile", nil, nil, nil, invoker)
+			}
+			assert.Equal(t, tc.wantCalls, calls)
+			assert.Equal(t, tc.wantCode, status.Code(err))
+
+			// 每个方法一个熔断器，互不影响
+			err = interceptor(context.Background(), "/user.UserService/Login", nil, nil, nil, invoker)
+			assert.Equal(t, tc.wantCalls+1, calls)
+			assert.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"test/webook/pkg/grpcx/interceptors/ratelimit"
+	"test/webook/pkg/limiter"
+	"test/webook/pkg/logger"
+	"time"
+)
+
+type InterceptorBuilder struct {
+	limiter limiter.ConcurrencyLimiter
+	prefix  string
+	keyFn   ratelimit.KeyFunc
+	// 大于 0 的时候拿不到许可会排队等待，否则直接拒绝
+	waitTimeout time.Duration
+	l           logger.Logger
+}
+
+func NewInterceptorBuilder(l limiter.ConcurrencyLimiter) *InterceptorBuilder {
+	return &InterceptorBuilder{
+		limiter: l,
+		prefix:  "concurrency",
+		l:       logger.NewNoLogger(),
+	}
+}
+
+func (b *InterceptorBuilder) Prefix(prefix string) *InterceptorBuilder {
+	b.prefix = prefix
+	return b
+}
+
+// KeyFn 设置 key 的提取方式，例如按照 fullMethod 区分接口，不设置的话整个服务共用一个 key
+func (b *InterceptorBuilder) KeyFn(fn ratelimit.KeyFunc) *InterceptorBuilder {
+	b.keyFn = fn
+	return b
+}
+
+// Wait 拿不到许可的时候最多排队等待 timeout
+func (b *InterceptorBuilder) Wait(timeout time.Duration) *InterceptorBuilder {
+	b.waitTimeout = timeout
+	return b
+}
+
+func (b *InterceptorBuilder) Logger(l logger.Logger) *InterceptorBuilder {
+	b.l = l
+	return b
+}
+
+func (b *InterceptorBuilder) BuildUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		release, err := b.acquire(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+func (b *InterceptorBuilder) BuildStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, err := b.acquire(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return handler(srv, ss)
+	}
+}
+
+func (b *InterceptorBuilder) acquire(ctx context.Context, fullMethod string) (func(), error) {
+	key := b.prefix
+	if b.keyFn != nil {
+		key = key + ":" + b.keyFn(ctx, fullMethod)
+	}
+
+	var (
+		release func()
+		err     error
+	)
+	if b.waitTimeout <= 0 {
+		release, err = b.limiter.TryAcquire(ctx, key)
+	} else {
+		waitCtx, cancel := context.WithTimeout(ctx, b.waitTimeout)
+		release, err = b.limiter.Acquire(waitCtx, key)
+		cancel()
+	}
+
+	switch {
+	case err == nil:
+		return release, nil
+	case errors.Is(err, limiter.ErrConcurrencyLimited) || errors.Is(err, context.DeadlineExceeded):
+		return nil, status.Error(codes.ResourceExhausted, "too many in-flight requests")
+	case errors.Is(err, context.Canceled):
+		// 排队的时候客户端取消了请求，不需要记录
+		return nil, status.Error(codes.Canceled, err.Error())
+	default:
+		b.l.Error("获取并发许可失败", logger.Error(err), logger.Any("method", fullMethod))
+		return nil, status.Error(codes.Internal, "concurrency limiter error")
+	}
+}
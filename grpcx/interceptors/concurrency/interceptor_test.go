@@ -0,0 +1,93 @@
+package concurrency
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"test/webook/pkg/limiter"
+	"testing"
+	"time"
+)
+
+func TestInterceptorBuilder(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 先占住的许可的 key
+		held    string
+		wait    time.Duration
+		reqCtx  func() (context.Context, context.CancelFunc)
+		keyFunc func(ctx context.Context, fullMethod string) string
+
+		wantCalled bool
+		wantCode   codes.Code
+	}{
+		{
+			name:       "拿到许可",
+			held:       "concurrency:other",
+			wantCalled: true,
+			wantCode:   codes.OK,
+		},
+		{
+			name:     "直接拒绝",
+			held:     "concurrency",
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name:     "排队超时",
+			held:     "concurrency",
+			wait:     10 * time.Millisecond,
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name: "排队的时候客户端取消",
+			held: "concurrency",
+			wait: time.Second,
+			reqCtx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantCode: codes.Canceled,
+		},
+		{
+			name: "按照方法区分",
+			held: "concurrency:/user.UserService/Login",
+			keyFunc: func(ctx context.Context, fullMethod string) string {
+				return fullMethod
+			},
+			wantCalled: true,
+			wantCode:   codes.OK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := limiter.NewLocalConcurrencyLimiter(1)
+			release, err := l.TryAcquire(context.Background(), tc.held)
+			require.NoError(t, err)
+			defer release()
+
+			b := NewInterceptorBuilder(l).Wait(tc.wait)
+			if tc.keyFunc != nil {
+				b = b.KeyFn(tc.keyFunc)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			if tc.reqCtx != nil {
+				ctx, cancel = tc.reqCtx()
+			}
+			defer cancel()
+
+			called := false
+			_, err = b.BuildUnaryServerInterceptor()(ctx, nil,
+				&grpc.UnaryServerInfo{FullMethod: "/user.UserService/Profile"},
+				func(ctx context.Context, req any) (any, error) {
+					called = true
+					return nil, nil
+				})
+			assert.Equal(t, tc.wantCalled, called)
+			assert.Equal(t, tc.wantCode, status.Code(err))
+		})
+	}
+}
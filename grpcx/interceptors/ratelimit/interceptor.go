@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"math"
+	"strconv"
+	"test/webook/pkg/limiter"
+	"test/webook/pkg/logger"
+	"time"
+)
+
+type InterceptorBuilder struct {
+	limiter limiter.Limiter
+	prefix  string
+	keyFn   KeyFunc
+	l       logger.Logger
+}
+
+func NewInterceptorBuilder(l limiter.Limiter) *InterceptorBuilder {
+	return &InterceptorBuilder{
+		limiter: l,
+		prefix:  "rate_limit",
+		l:       logger.NewNoLogger(),
+	}
+}
+
+func (b *InterceptorBuilder) Prefix(prefix string) *InterceptorBuilder {
+	b.prefix = prefix
+	return b
+}
+
+// KeyFn 设置 key 的提取方式，不设置的话整个服务共用一个 key
+func (b *InterceptorBuilder) KeyFn(fn KeyFunc) *InterceptorBuilder {
+	b.keyFn = fn
+	return b
+}
+
+func (b *InterceptorBuilder) Logger(l logger.Logger) *InterceptorBuilder {
+	b.l = l
+	return b
+}
+
+func (b *InterceptorBuilder) BuildUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		key := b.key(ctx, info.FullMethod)
+		trailer, err := b.limit(ctx, key, info.FullMethod)
+		if trailer != nil {
+			_ = grpc.SetTrailer(ctx, trailer)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if fb, ok := b.limiter.(limiter.FeedbackLimiter); ok {
+			start := time.Now()
+			defer func() {
+				fb.Done(ctx, key, time.Since(start))
+			}()
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (b *InterceptorBuilder) BuildStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		key := b.key(ctx, info.FullMethod)
+		trailer, err := b.limit(ctx, key, info.FullMethod)
+		if trailer != nil {
+			ss.SetTrailer(trailer)
+		}
+		if err != nil {
+			return err
+		}
+
+		if fb, ok := b.limiter.(limiter.FeedbackLimiter); ok {
+			start := time.Now()
+			defer func() {
+				fb.Done(ctx, key, time.Since(start))
+			}()
+		}
+		return handler(srv, ss)
+	}
+}
+
+// limit 被限流的时候返回 codes.ResourceExhausted。
+// 限流器支持 limiter.DecisionLimiter 的时候，配额信息和重试时间放在 trailer 里面
+func (b *InterceptorBuilder) limit(ctx context.Context, key, fullMethod string) (metadata.MD, error) {
+	dl, ok := b.limiter.(limiter.DecisionLimiter)
+	if !ok {
+		limited, err := b.limiter.Limit(ctx, key)
+		if err != nil {
+			b.l.Error("限流器出错", logger.Error(err), logger.Any("method", fullMethod))
+			return nil, status.Error(codes.Internal, "limiter error")
+		}
+		if limited {
+			return nil, status.Error(codes.ResourceExhausted, "rate limited")
+		}
+		return nil, nil
+	}
+
+	d, err := dl.Decide(ctx, key)
+	if err != nil {
+		b.l.Error("限流器出错", logger.Error(err), logger.Any("method", fullMethod))
+		return nil, status.Error(codes.Internal, "limiter error")
+	}
+	md := metadata.Pairs(
+		"x-ratelimit-limit", strconv.FormatInt(d.Limit, 10),
+		"x-ratelimit-remaining", strconv.FormatInt(d.Remaining, 10),
+		"x-ratelimit-reset", strconv.FormatInt(d.ResetAt.Unix(), 10),
+	)
+	if d.Allowed {
+		return md, nil
+	}
+	md.Set("retry-after", strconv.FormatInt(int64(math.Ceil(d.RetryAfter.Seconds())), 10))
+	return md, status.Error(codes.ResourceExhausted, "rate limited")
+}
+
+func (b *InterceptorBuilder) key(ctx context.Context, fullMethod string) string {
+	if b.keyFn == nil {
+		return b.prefix
+	}
+	return b.prefix + ":" + b.keyFn(ctx, fullMethod)
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"test/webook/pkg/limiter"
+	"testing"
+	"time"
+)
+
+func TestInterceptorBuilder(t *testing.T) {
+	testCases := []struct {
+		name string
+		l    limiter.Limiter
+		// 之前已经调用过几次
+		before int
+
+		wantCalled  bool
+		wantCode    codes.Code
+		wantTrailer map[string]string
+	}{
+		{
+			name:       "放行",
+			l:          limiter.NewLocalSlideWindowLimiter(time.Minute, 2),
+			wantCalled: true,
+			wantCode:   codes.OK,
+			wantTrailer: map[string]string{
+				"x-ratelimit-limit":     "2",
+				"x-ratelimit-remaining": "1",
+			},
+		},
+		{
+			name:     "限流",
+			l:        limiter.NewLocalSlideWindowLimiter(time.Minute, 2),
+			before:   2,
+			wantCode: codes.ResourceExhausted,
+			wantTrailer: map[string]string{
+				"x-ratelimit-limit":     "2",
+				"x-ratelimit-remaining": "0",
+				"retry-after":           "60",
+			},
+		},
+		{
+			name:     "不支持 Decide 的限流器",
+			l:        mockLimiter{limited: true},
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name:     "限流器出错",
+			l:        mockLimiter{err: errors.New("mock error")},
+			wantCode: codes.Internal,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			interceptor := NewInterceptorBuilder(tc.l).BuildUnaryServerInterceptor()
+			info := &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/GetById"}
+			handler := func(ctx context.Context, req any) (any, error) {
+				return nil, nil
+			}
+			for i := 0; i < tc.before; i++ {
+				_, err := interceptor(newTrailerContext(&mockStream{}), nil, info, handler)
+				assert.NoError(t, err)
+			}
+
+			stream := &mockStream{}
+			called := false
+			_, err := interceptor(newTrailerContext(stream), nil, info, func(ctx context.Context, req any) (any, error) {
+				called = true
+				return nil, nil
+			})
+			assert.Equal(t, tc.wantCalled, called)
+			assert.Equal(t, tc.wantCode, status.Code(err))
+			if tc.wantTrailer == nil {
+				assert.Empty(t, stream.trailer)
+				return
+			}
+			for k, v := range tc.wantTrailer {
+				assert.Equal(t, []string{v}, stream.trailer.Get(k), k)
+			}
+			assert.Len(t, stream.trailer.Get("x-ratelimit-reset"), 1)
+			if _, ok := tc.wantTrailer["retry-after"]; !ok {
+				assert.Empty(t, stream.trailer.Get("retry-after"))
+			}
+		})
+	}
+}
+
+func newTrailerContext(stream *mockStream) context.Context {
+	return grpc.NewContextWithServerTransportStream(context.Background(), stream)
+}
+
+// mockStream 记录 grpc.SetTrailer 设置的 trailer
+type mockStream struct {
+	trailer metadata.MD
+}
+
+func (m *mockStream) Method() string {
+	return ""
+}
+
+func (m *mockStream) SetHeader(md metadata.MD) error {
+	return nil
+}
+
+func (m *mockStream) SendHeader(md metadata.MD) error {
+	return nil
+}
+
+func (m *mockStream) SetTrailer(md metadata.MD) error {
+	m.trailer = metadata.Join(m.trailer, md)
+	return nil
+}
+
+type mockLimiter struct {
+	limited bool
+	err     error
+}
+
+func (m mockLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return m.limited, m.err
+}
+
+func (m mockLimiter) LimitN(ctx context.Context, key string, n int64) (bool, error) {
+	return m.limited, m.err
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"strings"
+)
+
+// KeyFunc 从请求中提取限流的 key
+type KeyFunc func(ctx context.Context, fullMethod string) string
+
+// FullMethod 按接口限流，例如 /user.v1.UserService/GetById
+func FullMethod() KeyFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		return fullMethod
+	}
+}
+
+// Peer 按调用方的 IP 限流
+func Peer() KeyFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		p, ok := peer.FromContext(ctx)
+		if !ok || p.Addr == nil {
+			return ""
+		}
+		addr := p.Addr.String()
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return addr
+		}
+		return host
+	}
+}
+
+// Metadata 按 metadata 里面的值限流，例如调用方的应用名
+func Metadata(key string) KeyFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		vals := metadata.ValueFromIncomingContext(ctx, key)
+		if len(vals) == 0 {
+			return ""
+		}
+		return vals[0]
+	}
+}
+
+// Compose 组合多个 KeyFunc，例如 Compose(FullMethod(), Peer()) 就是每个调用方在每个接口上单独限流
+func Compose(fns ...KeyFunc) KeyFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		segs := make([]string, 0, len(fns))
+		for _, fn := range fns {
+			segs = append(segs, fn(ctx, fullMethod))
+		}
+		return strings.Join(segs, ":")
+	}
+}
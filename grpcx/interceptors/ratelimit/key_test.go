@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"testing"
+)
+
+func TestKeyFunc(t *testing.T) {
+	const fullMethod = "/user.v1.UserService/GetById"
+	withPeer := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 12345},
+	})
+	testCases := []struct {
+		name  string
+		ctx   context.Context
+		keyFn KeyFunc
+
+		wantKey string
+	}{
+		{
+			name:    "接口",
+			ctx:     context.Background(),
+			keyFn:   FullMethod(),
+			wantKey: fullMethod,
+		},
+		{
+			name:    "调用方 IP",
+			ctx:     withPeer,
+			keyFn:   Peer(),
+			wantKey: "10.0.0.1",
+		},
+		{
+			name: "地址没有端口",
+			ctx: peer.NewContext(context.Background(), &peer.Peer{
+				Addr: &net.UnixAddr{Name: "/tmp/grpc.sock", Net: "unix"},
+			}),
+			keyFn:   Peer(),
+			wantKey: "/tmp/grpc.sock",
+		},
+		{
+			name:  "没有调用方信息",
+			ctx:   context.Background(),
+			keyFn: Peer(),
+		},
+		{
+			name: "metadata",
+			ctx: metadata.NewIncomingContext(context.Background(),
+				metadata.Pairs("app", "webook", "app", "other")),
+			keyFn:   Metadata("app"),
+			wantKey: "webook",
+		},
+		{
+			name:  "没有 metadata",
+			ctx:   context.Background(),
+			keyFn: Metadata("app"),
+		},
+		{
+			name:    "组合",
+			ctx:     withPeer,
+			keyFn:   Compose(FullMethod(), Peer()),
+			wantKey: fullMethod + ":10.0.0.1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantKey, tc.keyFn(tc.ctx, fullMethod))
+		})
+	}
+}
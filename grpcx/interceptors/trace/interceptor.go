@@ -0,0 +1,170 @@
+package trace
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+const instrumentationName = "test/webook/pkg/grpcx/interceptors/trace"
+
+type InterceptorBuilder struct {
+	tp         trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// NewInterceptorBuilder 默认使用全局的 TracerProvider 和 propagator
+func NewInterceptorBuilder() *InterceptorBuilder {
+	return &InterceptorBuilder{}
+}
+
+func (b *InterceptorBuilder) TracerProvider(tp trace.TracerProvider) *InterceptorBuilder {
+	b.tp = tp
+	return b
+}
+
+func (b *InterceptorBuilder) Propagator(p propagation.TextMapPropagator) *InterceptorBuilder {
+	b.propagator = p
+	return b
+}
+
+func (b *InterceptorBuilder) BuildUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	tracer, propagator := b.tracer(), b.getPropagator()
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, span := startServerSpan(ctx, tracer, propagator, info.FullMethod)
+		defer span.End()
+		resp, err := handler(ctx, req)
+		endSpan(span, err)
+		return resp, err
+	}
+}
+
+func (b *InterceptorBuilder) BuildStreamServerInterceptor() grpc.StreamServerInterceptor {
+	tracer, propagator := b.tracer(), b.getPropagator()
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startServerSpan(ss.Context(), tracer, propagator, info.FullMethod)
+		defer span.End()
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		endSpan(span, err)
+		return err
+	}
+}
+
+func (b *InterceptorBuilder) BuildUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	tracer, propagator := b.tracer(), b.getPropagator()
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := tracer.Start(ctx, spanName(method),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(methodAttrs(method)...),
+			trace.WithAttributes(attribute.String("server.address", cc.Target())),
+		)
+		defer span.End()
+
+		// 把 trace 放进 metadata 里面传给服务端
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+		propagator.Inject(ctx, metadataCarrier(md))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		endSpan(span, err)
+		return err
+	}
+}
+
+func startServerSpan(ctx context.Context, tracer trace.Tracer,
+	propagator propagation.TextMapPropagator, fullMethod string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = propagator.Extract(ctx, metadataCarrier(md))
+	}
+	return tracer.Start(ctx, spanName(fullMethod),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(methodAttrs(fullMethod)...),
+	)
+}
+
+func (b *InterceptorBuilder) tracer() trace.Tracer {
+	tp := b.tp
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(instrumentationName)
+}
+
+func (b *InterceptorBuilder) getPropagator() propagation.TextMapPropagator {
+	if b.propagator == nil {
+		return otel.GetTextMapPropagator()
+	}
+	return b.propagator
+}
+
+// endSpan 记录 gRPC 状态码，出错的时候标记 span
+func endSpan(span trace.Span, err error) {
+	st, _ := status.FromError(err)
+	span.SetAttributes(attribute.String("rpc.grpc.status_code", st.Code().String()))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, st.Message())
+	}
+}
+
+// spanName 去掉 fullMethod 开头的 /，例如 user.v1.UserService/GetById
+func spanName(fullMethod string) string {
+	return strings.TrimPrefix(fullMethod, "/")
+}
+
+func methodAttrs(fullMethod string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("rpc.system", "grpc")}
+	service, method, ok := strings.Cut(spanName(fullMethod), "/")
+	if !ok {
+		return attrs
+	}
+	return append(attrs,
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", method),
+	)
+}
+
+// serverStream 替换掉 stream 的 context，让 handler 能拿到 span
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// metadataCarrier 让 propagator 能读写 gRPC 的 metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	vals := metadata.MD(c).Get(key)
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
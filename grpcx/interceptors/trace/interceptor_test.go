@@ -0,0 +1,138 @@
+package trace
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+const fullMethod = "/user.v1.UserService/GetById"
+
+func TestInterceptorBuilder(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	b := NewInterceptorBuilder().TracerProvider(tp).Propagator(propagation.TraceContext{})
+	client := b.BuildUnaryClientInterceptor()
+	server := b.BuildUnaryServerInterceptor()
+
+	cc, err := grpc.NewClient("passthrough:///localhost:8090", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+
+	testCases := []struct {
+		name string
+		err  error
+
+		wantStatus codes.Code
+		wantCode   string
+	}{
+		{
+			name:       "成功",
+			wantStatus: codes.Unset,
+			wantCode:   "OK",
+		},
+		{
+			name:       "失败",
+			err:        status.Error(grpccodes.NotFound, "user not found"),
+			wantStatus: codes.Error,
+			wantCode:   "NotFound",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter.Reset()
+			var handlerSpan trace.SpanContext
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "x-biz", "webook")
+			// invoker 把客户端发出去的 metadata 交给服务端的拦截器，模拟一次调用
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, ok := metadata.FromOutgoingContext(ctx)
+				require.True(t, ok)
+				assert.Equal(t, []string{"webook"}, md.Get("x-biz"))
+				ctx = metadata.NewIncomingContext(context.Background(), md)
+				_, err := server(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+					func(ctx context.Context, req any) (any, error) {
+						handlerSpan = trace.SpanContextFromContext(ctx)
+						return nil, tc.err
+					})
+				return err
+			}
+			err := client(ctx, fullMethod, nil, nil, cc, invoker)
+			assert.Equal(t, tc.err, err)
+
+			// 服务端的 span 先结束
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 2)
+			serverSpan, clientSpan := spans[0], spans[1]
+			assert.Equal(t, trace.SpanKindServer, serverSpan.SpanKind)
+			assert.Equal(t, trace.SpanKindClient, clientSpan.SpanKind)
+			for _, span := range spans {
+				assert.Equal(t, "user.v1.UserService/GetById", span.Name)
+				assert.Contains(t, span.Attributes, attribute.String("rpc.service", "user.v1.UserService"))
+				assert.Contains(t, span.Attributes, attribute.String("rpc.method", "GetById"))
+				assert.Contains(t, span.Attributes, attribute.String("rpc.grpc.status_code", tc.wantCode))
+				assert.Equal(t, tc.wantStatus, span.Status.Code)
+			}
+
+			// 服务端通过 metadata 接上客户端的 trace
+			assert.False(t, clientSpan.Parent.IsValid())
+			assert.Equal(t, clientSpan.SpanContext.TraceID(), serverSpan.SpanContext.TraceID())
+			assert.Equal(t, clientSpan.SpanContext.SpanID(), serverSpan.Parent.SpanID())
+			assert.True(t, serverSpan.Parent.IsRemote())
+			assert.Equal(t, serverSpan.SpanContext.SpanID(), handlerSpan.SpanID())
+		})
+	}
+}
+
+func TestInterceptorBuilder_Stream(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	propagator := propagation.TraceContext{}
+	interceptor := NewInterceptorBuilder().TracerProvider(tp).Propagator(propagator).BuildStreamServerInterceptor()
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	md := metadata.MD{}
+	propagator.Inject(trace.ContextWithSpanContext(context.Background(), parent), metadataCarrier(md))
+	ss := &mockServerStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+
+	var handlerSpan trace.SpanContext
+	err := interceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: fullMethod}, func(srv any, stream grpc.ServerStream) error {
+		handlerSpan = trace.SpanContextFromContext(stream.Context())
+		return nil
+	})
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+	assert.Equal(t, parent.TraceID(), span.SpanContext.TraceID())
+	assert.Equal(t, parent.SpanID(), span.Parent.SpanID())
+	// handler 从 stream 里面拿到的是拦截器创建的 span
+	assert.Equal(t, span.SpanContext.SpanID(), handlerSpan.SpanID())
+}
+
+type mockServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *mockServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,118 @@
+package limiter
+
+import (
+	"context"
+	"go.uber.org/atomic"
+	"math"
+	"sync"
+	"time"
+)
+
+// BBRLimiter 参考 TCP BBR 的自适应限流。
+// 统计窗口内每个桶的最大通过数和最小响应时间，估算出系统能承受的最大并发数，
+// CPU 使用率超过阈值的时候，正在处理的请求数超过最大并发数就拒绝请求。
+// 限流是实例级别的，忽略 key
+type BBRLimiter struct {
+	cpu          func() int64
+	cpuThreshold int64
+
+	bucketDur time.Duration
+	lock      sync.Mutex
+	buckets   []bbrBucket
+
+	inFlight *atomic.Int64
+	// 上一次拒绝请求的时间，冷却时间内即便 CPU 降下来了也继续按照最大并发数限流，避免抖动
+	lastDrop *atomic.Int64
+	coolDown time.Duration
+	now      func() time.Time
+}
+
+type bbrBucket struct {
+	// 桶的编号，用来判断桶里的数据是否过期
+	idx   int64
+	pass  int64
+	rtSum time.Duration
+}
+
+// NewBBRLimiter window 是统计窗口，被切成 bucketCnt 个桶，cpuThreshold 是触发限流的 CPU 使用率（千分比）
+func NewBBRLimiter(window time.Duration, bucketCnt int, cpuThreshold int64) *BBRLimiter {
+	return &BBRLimiter{
+		cpu:          CPUUsage,
+		cpuThreshold: cpuThreshold,
+		bucketDur:    window / time.Duration(bucketCnt),
+		buckets:      make([]bbrBucket, bucketCnt),
+		inFlight:     atomic.NewInt64(0),
+		lastDrop:     atomic.NewInt64(0),
+		coolDown:     time.Second,
+		now:          time.Now,
+	}
+}
+
+func (b *BBRLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return b.LimitN(ctx, key, 1)
+}
+
+// LimitN 自适应限流按照请求数统计，n 只做校验
+func (b *BBRLimiter) LimitN(ctx context.Context, key string, n int64) (bool, error) {
+	if n <= 0 {
+		return false, ErrInvalidN
+	}
+	if b.shouldDrop() {
+		b.lastDrop.Store(b.now().UnixNano())
+		return true, nil
+	}
+	b.inFlight.Inc()
+	return false, nil
+}
+
+func (b *BBRLimiter) Done(ctx context.Context, key string, rt time.Duration) {
+	b.inFlight.Dec()
+
+	idx := b.now().UnixNano() / int64(b.bucketDur)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	bucket := &b.buckets[idx%int64(len(b.buckets))]
+	if bucket.idx != idx {
+		*bucket = bbrBucket{idx: idx}
+	}
+	bucket.pass++
+	bucket.rtSum += rt
+}
+
+func (b *BBRLimiter) shouldDrop() bool {
+	if b.cpu() < b.cpuThreshold {
+		last := b.lastDrop.Load()
+		if last == 0 || b.now().UnixNano()-last > int64(b.coolDown) {
+			return false
+		}
+	}
+	inFlight := b.inFlight.Load()
+	return inFlight > 1 && inFlight > b.maxInFlight()
+}
+
+// maxInFlight 最大并发数 = 每秒最大通过数 * 最小响应时间
+func (b *BBRLimiter) maxInFlight() int64 {
+	cur := b.now().UnixNano() / int64(b.bucketDur)
+	maxPass := int64(1)
+	minRT := time.Duration(math.MaxInt64)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, bucket := range b.buckets {
+		// 当前桶还没统计完，不参与计算
+		if bucket.pass == 0 || bucket.idx == cur || cur-bucket.idx >= int64(len(b.buckets)) {
+			continue
+		}
+		if bucket.pass > maxPass {
+			maxPass = bucket.pass
+		}
+		if rt := bucket.rtSum / time.Duration(bucket.pass); rt < minRT {
+			minRT = rt
+		}
+	}
+	if minRT == time.Duration(math.MaxInt64) || minRT <= 0 {
+		minRT = time.Millisecond
+	}
+	bucketsPerSecond := float64(time.Second) / float64(b.bucketDur)
+	return int64(math.Ceil(float64(maxPass) * bucketsPerSecond * minRT.Seconds()))
+}
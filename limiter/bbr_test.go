@@ -0,0 +1,103 @@
+package limiter
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newTestBBRLimiter() (*BBRLimiter, *time.Time, *int64) {
+	now := time.UnixMilli(1_000_000)
+	cpu := int64(0)
+	// 每个桶 100 毫秒
+	b := NewBBRLimiter(time.Second, 10, 800)
+	b.now = func() time.Time {
+		return now
+	}
+	b.cpu = func() int64 {
+		return cpu
+	}
+	return b, &now, &cpu
+}
+
+func TestBBRLimiter_MaxInFlight(t *testing.T) {
+	b, now, _ := newTestBBRLimiter()
+	ctx := context.Background()
+
+	// 没有统计数据的时候按照 1 个请求、1 毫秒计算
+	assert.Equal(t, int64(1), b.maxInFlight())
+
+	pass(t, b, 20, 50*time.Millisecond)
+	// 当前桶还没统计完，不参与计算
+	assert.Equal(t, int64(1), b.maxInFlight())
+
+	*now = now.Add(100 * time.Millisecond)
+	pass(t, b, 10, 20*time.Millisecond)
+	*now = now.Add(100 * time.Millisecond)
+	// 最大通过数 20 * 每秒 10 个桶 * 最小响应时间 20ms
+	assert.Equal(t, int64(4), b.maxInFlight())
+
+	// 滑出窗口之后恢复默认值
+	*now = now.Add(time.Second)
+	assert.Equal(t, int64(1), b.maxInFlight())
+
+	_, err := b.LimitN(ctx, "a", 0)
+	assert.Equal(t, ErrInvalidN, err)
+}
+
+func TestBBRLimiter_Drop(t *testing.T) {
+	b, now, cpu := newTestBBRLimiter()
+	ctx := context.Background()
+
+	pass(t, b, 20, 50*time.Millisecond)
+	*now = now.Add(100 * time.Millisecond)
+	require.Equal(t, int64(10), b.maxInFlight())
+
+	// CPU 没有超过阈值，不限流
+	for i := 0; i < 20; i++ {
+		limited, err := b.Limit(ctx, "a")
+		require.NoError(t, err)
+		require.False(t, limited)
+	}
+	for i := 0; i < 20; i++ {
+		b.Done(ctx, "a", 50*time.Millisecond)
+	}
+
+	*cpu = 900
+	for i := 0; i <= 10; i++ {
+		limited, err := b.Limit(ctx, "a")
+		require.NoError(t, err)
+		require.False(t, limited)
+	}
+	limited, err := b.Limit(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, limited)
+
+	// CPU 降下来了，冷却时间内依旧按照最大并发数限流
+	*cpu = 100
+	*now = now.Add(500 * time.Millisecond)
+	limited, err = b.Limit(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, limited)
+
+	// 冷却时间过了就不再限流
+	*now = now.Add(time.Second + time.Millisecond)
+	limited, err = b.Limit(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, limited)
+}
+
+// pass 放行 cnt 个请求，每个耗时 rt
+func pass(t *testing.T, b *BBRLimiter, cnt int, rt time.Duration) {
+	ctx := context.Background()
+	for i := 0; i < cnt; i++ {
+		limited, err := b.Limit(ctx, "a")
+		require.NoError(t, err)
+		require.False(t, limited)
+	}
+	for i := 0; i < cnt; i++ {
+		b.Done(ctx, "a", rt)
+	}
+}
@@ -0,0 +1,16 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrConcurrencyLimited = errors.New("too many in-flight requests")
+
+// ConcurrencyLimiter 限制同时处理的请求数量，和限流器不同，请求结束之后要归还许可
+type ConcurrencyLimiter interface {
+	// TryAcquire 获取许可，拿不到立刻返回 ErrConcurrencyLimited
+	TryAcquire(ctx context.Context, key string) (release func(), err error)
+	// Acquire 获取许可，拿不到就排队等待，直到拿到或者 ctx 过期
+	Acquire(ctx context.Context, key string) (release func(), err error)
+}
@@ -0,0 +1,16 @@
+local key = KEYS[1]
+local max = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+local lease = tonumber(ARGV[3])
+local id = ARGV[4]
+
+--删除过期的租约，持有者可能已经宕机了
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now)
+local cnt = redis.call('ZCARD', key)
+if cnt >= max then
+    return 0
+end
+
+redis.call('ZADD', key, now + lease, id)
+redis.call('PEXPIRE', key, lease)
+return 1
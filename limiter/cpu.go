@@ -0,0 +1,74 @@
+package limiter
+
+import (
+	"bufio"
+	"go.uber.org/atomic"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	cpuOnce  sync.Once
+	cpuUsage = atomic.NewInt64(0)
+)
+
+// CPUUsage 最近一段时间的 CPU 使用率，千分比。
+// 数据来自 /proc/stat，只支持 linux，其他系统上总是 0
+func CPUUsage() int64 {
+	cpuOnce.Do(func() {
+		go sampleCPU(time.Millisecond * 500)
+	})
+	return cpuUsage.Load()
+}
+
+func sampleCPU(interval time.Duration) {
+	idle, total, err := readCPUStat()
+	if err != nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		curIdle, curTotal, err := readCPUStat()
+		if err != nil || curTotal <= total {
+			continue
+		}
+		usage := 1000 - (curIdle-idle)*1000/(curTotal-total)
+		idle, total = curIdle, curTotal
+		// 指数滑动平均，避免瞬间的抖动
+		cpuUsage.Store((cpuUsage.Load()*8 + usage*2) / 10)
+	}
+}
+
+// readCPUStat 读取 /proc/stat 第一行的 cpu 汇总数据
+func readCPUStat() (idle int64, total int64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return 0, 0, errInvalidCPUStat
+	}
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, errInvalidCPUStat
+	}
+	for i, field := range fields[1:] {
+		val, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		total += val
+		// idle 和 iowait
+		if i == 3 || i == 4 {
+			idle += val
+		}
+	}
+	return idle, total, nil
+}
@@ -0,0 +1,12 @@
+package limiter
+
+import "errors"
+
+var (
+	// ErrInvalidN n 小于等于 0。n 往往来自客户端的输入，例如批量操作的数量，不校验的话可以绕过限流。
+	// lua 脚本里面也会再校验一次，返回同样的错误信息
+	ErrInvalidN = errors.New("n must be positive")
+
+	errInvalidResult  = errors.New("invalid limiter script result")
+	errInvalidCPUStat = errors.New("invalid /proc/stat content")
+)
@@ -1,16 +1,45 @@
+--Redis 5 之前脚本里使用 TIME 之后写数据需要开启命令复制
+if redis.replicate_commands then
+    redis.replicate_commands()
+end
+
 local key = KEYS[1]
 local window = tonumber(ARGV[1])
-local now = tonumber(ARGV[2])
-local threshold = tonumber(ARGV[3])
+local threshold = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+--每次调用唯一的 id，避免同一毫秒的请求被合并成一个成员
+local id = ARGV[4]
+if n == nil or n <= 0 then
+    return redis.error_reply('n must be positive')
+end
+
+--使用 Redis 的时间，避免各个实例的时钟不一致
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
 
 --删除窗口之外的数据
 redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
 local cnt = redis.call('ZCOUNT', key, '-inf', '+inf')
 
-if cnt >= threshold then
-    return 'true'
+--返回 {是否放行, 剩余配额, 窗口清空的时间, 重试间隔}
+if cnt + n > threshold then
+    local retry = window
+    --等到足够多的请求滑出窗口
+    local need = redis.call('ZRANGE', key, cnt + n - threshold - 1, cnt + n - threshold - 1, 'WITHSCORES')
+    if #need > 0 then
+        retry = tonumber(need[2]) + window - now
+    end
+    local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+    local reset = now
+    if #newest > 0 then
+        reset = tonumber(newest[2]) + window
+    end
+    return {0, threshold - cnt, reset, retry}
 else
-    redis.call('ZADD', key, now, now)
+    --每个配额一个成员，n 超过 threshold 的时候不会走到这里，所以一次最多写 threshold 个成员
+    for i = 1, n do
+        redis.call('ZADD', key, now, id .. ':' .. i)
+    end
     redis.call('PEXPIRE', key, window)
-    return 'false'
-end
\ No newline at end of file
+    return {1, threshold - cnt - n, now + window, 0}
+end
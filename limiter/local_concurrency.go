@@ -0,0 +1,84 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// LocalConcurrencyLimiter 本地信号量，每个 key 最多 max 个请求同时处理
+type LocalConcurrencyLimiter struct {
+	max  int64
+	lock sync.Mutex
+	sems map[string]*semaphore
+}
+
+type semaphore struct {
+	ch chan struct{}
+	// 持有和等待这个信号量的请求数，为 0 的时候可以删除
+	refs int64
+}
+
+// NewLocalConcurrencyLimiter max 必须是正数
+func NewLocalConcurrencyLimiter(max int64) *LocalConcurrencyLimiter {
+	if max <= 0 {
+		panic(fmt.Sprintf("limiter: max %d must be positive", max))
+	}
+	return &LocalConcurrencyLimiter{
+		max:  max,
+		sems: make(map[string]*semaphore),
+	}
+}
+
+func (l *LocalConcurrencyLimiter) TryAcquire(ctx context.Context, key string) (func(), error) {
+	sem := l.ref(key)
+	select {
+	case sem.ch <- struct{}{}:
+		return l.releaseFunc(key, sem), nil
+	default:
+		l.unref(key, sem)
+		return nil, ErrConcurrencyLimited
+	}
+}
+
+func (l *LocalConcurrencyLimiter) Acquire(ctx context.Context, key string) (func(), error) {
+	sem := l.ref(key)
+	select {
+	case sem.ch <- struct{}{}:
+		return l.releaseFunc(key, sem), nil
+	case <-ctx.Done():
+		l.unref(key, sem)
+		return nil, ctx.Err()
+	}
+}
+
+func (l *LocalConcurrencyLimiter) releaseFunc(key string, sem *semaphore) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-sem.ch
+			l.unref(key, sem)
+		})
+	}
+}
+
+func (l *LocalConcurrencyLimiter) ref(key string) *semaphore {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	sem, ok := l.sems[key]
+	if !ok {
+		sem = &semaphore{ch: make(chan struct{}, l.max)}
+		l.sems[key] = sem
+	}
+	sem.refs++
+	return sem
+}
+
+func (l *LocalConcurrencyLimiter) unref(key string, sem *semaphore) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	sem.refs--
+	if sem.refs == 0 {
+		delete(l.sems, key)
+	}
+}
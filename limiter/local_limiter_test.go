@@ -0,0 +1,289 @@
+package limiter
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLocalSlideWindowLimiter(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	l := NewLocalSlideWindowLimiter(time.Second, 2)
+	l.now = func() time.Time {
+		return now
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		d, err := l.Decide(ctx, "a")
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, int64(1-i), d.Remaining)
+		now = now.Add(100 * time.Millisecond)
+	}
+
+	d, err := l.Decide(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 800*time.Millisecond, d.RetryAfter)
+
+	// 不同的 key 互不影响
+	limited, err := l.Limit(ctx, "b")
+	require.NoError(t, err)
+	assert.False(t, limited)
+
+	// 第一个请求滑出窗口之后可以再放行一个
+	now = now.Add(800 * time.Millisecond)
+	d, err = l.Decide(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, int64(0), d.Remaining)
+}
+
+func TestLocalSlideWindowLimiterN(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	l := NewLocalSlideWindowLimiter(time.Second, 5)
+	l.now = func() time.Time {
+		return now
+	}
+	ctx := context.Background()
+
+	d, err := l.DecideN(ctx, "a", 2)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	now = now.Add(100 * time.Millisecond)
+	d, err = l.DecideN(ctx, "a", 3)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, int64(0), d.Remaining)
+
+	// 需要等前两个配额滑出窗口
+	d, err = l.DecideN(ctx, "a", 2)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 900*time.Millisecond, d.RetryAfter)
+}
+
+func TestNewLocalSlideWindowLimiter(t *testing.T) {
+	testCases := []struct {
+		name      string
+		window    time.Duration
+		threshold int64
+	}{
+		{
+			name:      "窗口是 0",
+			threshold: 1,
+		},
+		{
+			name:      "窗口是负数",
+			window:    -time.Second,
+			threshold: 1,
+		},
+		{
+			name:   "阈值是 0",
+			window: time.Second,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Panics(t, func() {
+				NewLocalSlideWindowLimiter(tc.window, tc.threshold)
+			})
+		})
+	}
+}
+
+func TestLocalLimiterInvalidN(t *testing.T) {
+	ctx := context.Background()
+	limiters := map[string]DecisionLimiter{
+		"slide window": NewLocalSlideWindowLimiter(time.Second, 1),
+		"token bucket": NewLocalTokenBucketLimiter(1, 1),
+	}
+	for name, l := range limiters {
+		t.Run(name, func(t *testing.T) {
+			limited, err := l.Limit(ctx, "a")
+			require.NoError(t, err)
+			require.False(t, limited)
+
+			for _, n := range []int64{0, -100} {
+				_, err = l.DecideN(ctx, "a", n)
+				assert.Equal(t, ErrInvalidN, err)
+				_, err = l.LimitN(ctx, "a", n)
+				assert.Equal(t, ErrInvalidN, err)
+			}
+			// 配额没有被负数的 n 补回来
+			limited, err = l.Limit(ctx, "a")
+			require.NoError(t, err)
+			assert.True(t, limited)
+		})
+	}
+}
+
+func TestLocalTokenBucketLimiter(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	l := NewLocalTokenBucketLimiter(3, 10)
+	l.now = func() time.Time {
+		return now
+	}
+	ctx := context.Background()
+
+	// 允许突发 capacity 个请求
+	for i := 0; i < 3; i++ {
+		limited, err := l.Limit(ctx, "a")
+		require.NoError(t, err)
+		assert.False(t, limited)
+	}
+	d, err := l.Decide(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 100*time.Millisecond, d.RetryAfter)
+
+	// 之后按照速率放行
+	now = now.Add(100 * time.Millisecond)
+	d, err = l.Decide(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	d, err = l.Decide(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+}
+
+func TestNewTokenBucketLimiter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		capacity int64
+		rate     float64
+	}{
+		{
+			name:     "容量是 0",
+			capacity: 0,
+			rate:     1,
+		},
+		{
+			name:     "速率是 0",
+			capacity: 1,
+			rate:     0,
+		},
+		{
+			name:     "速率是负数",
+			capacity: 1,
+			rate:     -1,
+		},
+		{
+			name:     "速率是 NaN",
+			capacity: 1,
+			rate:     math.NaN(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Panics(t, func() {
+				NewLocalTokenBucketLimiter(tc.capacity, tc.rate)
+			})
+			assert.Panics(t, func() {
+				NewRedisTokenBucketLimiter(nil, tc.capacity, tc.rate)
+			})
+		})
+	}
+}
+
+func TestLocalStoreEvict(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	s := newLocalStore[int](time.Second)
+	s.do("a", now, func(val *int) {
+		*val++
+	})
+	sd := s.shard("a")
+	assert.Len(t, sd.entries, 1)
+
+	// 过期的 key 重新访问的时候是零值
+	s.do("a", now.Add(2*time.Second), func(val *int) {
+		assert.Equal(t, 0, *val)
+	})
+
+	sd.sweep(now.Add(4 * time.Second))
+	assert.Len(t, sd.entries, 0)
+}
+
+func TestLocalTokenBucketLimiterReserve(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	l := NewLocalTokenBucketLimiter(10, 1)
+	l.now = func() time.Time {
+		return now
+	}
+	ctx := context.Background()
+
+	res, err := l.Reserve(ctx, "a", 8, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, int64(2), res.Remaining)
+
+	// 配额不够，预留失败
+	failed, err := l.Reserve(ctx, "a", 5, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, failed.Allowed)
+
+	// n 不是正数不能动预留，否则桶里的令牌反而会变少
+	assert.Equal(t, ErrInvalidN, l.Refund(ctx, res, -5))
+	assert.Equal(t, ErrInvalidN, l.Refund(ctx, res, 0))
+
+	// 只用掉了 3 个，归还 5 个
+	require.NoError(t, l.Refund(ctx, res, 5))
+	d, err := l.DecideN(ctx, "a", 7)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	// 不能重复归还
+	assert.Equal(t, ErrReservationExpired, l.Cancel(ctx, res))
+
+	// 过期之后不能再归还
+	res, err = l.Reserve(ctx, "b", 1, time.Second)
+	require.NoError(t, err)
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, ErrReservationExpired, l.Cancel(ctx, res))
+
+	// 过期的预留隔一段时间才清理一次
+	now = now.Add(reservationSweep)
+	_, err = l.Reserve(ctx, "c", 1, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, l.reservations, 1)
+}
+
+func TestNewConcurrencyLimiter(t *testing.T) {
+	testCases := []struct {
+		name  string
+		build func()
+	}{
+		{
+			name: "本地 max 是 0",
+			build: func() {
+				NewLocalConcurrencyLimiter(0)
+			},
+		},
+		{
+			name: "本地 max 是负数",
+			build: func() {
+				NewLocalConcurrencyLimiter(-1)
+			},
+		},
+		{
+			name: "Redis max 是 0",
+			build: func() {
+				NewRedisConcurrencyLimiter(nil, 0, time.Minute)
+			},
+		},
+		{
+			name: "Redis lease 是 0",
+			build: func() {
+				NewRedisConcurrencyLimiter(nil, 1, 0)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Panics(t, tc.build)
+		})
+	}
+}
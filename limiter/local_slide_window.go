@@ -0,0 +1,85 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LocalSlideWindowLimiter 本地滑动窗口限流，适合单机部署、测试，
+// 或者作为 Redis 不可用时的降级方案
+type LocalSlideWindowLimiter struct {
+	store     *localStore[[]time.Time]
+	window    time.Duration
+	threshold int64
+	now       func() time.Time
+}
+
+// NewLocalSlideWindowLimiter window 和 threshold 必须是正数
+func NewLocalSlideWindowLimiter(window time.Duration, threshold int64) *LocalSlideWindowLimiter {
+	if window <= 0 || threshold <= 0 {
+		panic(fmt.Sprintf("limiter: window %s and threshold %d must be positive", window, threshold))
+	}
+	return &LocalSlideWindowLimiter{
+		// 空闲超过一个窗口的 key，里面的请求都已经过期了，可以直接淘汰
+		store:     newLocalStore[[]time.Time](window),
+		window:    window,
+		threshold: threshold,
+		now:       time.Now,
+	}
+}
+
+func (l *LocalSlideWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return l.LimitN(ctx, key, 1)
+}
+
+func (l *LocalSlideWindowLimiter) LimitN(ctx context.Context, key string, n int64) (bool, error) {
+	d, err := l.DecideN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return !d.Allowed, nil
+}
+
+func (l *LocalSlideWindowLimiter) Decide(ctx context.Context, key string) (Decision, error) {
+	return l.DecideN(ctx, key, 1)
+}
+
+func (l *LocalSlideWindowLimiter) DecideN(ctx context.Context, key string, n int64) (Decision, error) {
+	if n <= 0 {
+		return Decision{}, ErrInvalidN
+	}
+	now := l.now()
+	d := Decision{Limit: l.threshold}
+	l.store.do(key, now, func(reqs *[]time.Time) {
+		//删除窗口之外的数据
+		start := now.Add(-l.window)
+		i := 0
+		for i < len(*reqs) && !(*reqs)[i].After(start) {
+			i++
+		}
+		*reqs = (*reqs)[i:]
+
+		cnt := int64(len(*reqs))
+		if cnt+n > l.threshold {
+			d.Remaining = l.threshold - cnt
+			d.ResetAt = now
+			if cnt > 0 {
+				d.ResetAt = (*reqs)[cnt-1].Add(l.window)
+			}
+			//等到足够多的请求滑出窗口
+			d.RetryAfter = l.window
+			if need := cnt + n - l.threshold - 1; need < cnt {
+				d.RetryAfter = (*reqs)[need].Add(l.window).Sub(now)
+			}
+			return
+		}
+		for i := int64(0); i < n; i++ {
+			*reqs = append(*reqs, now)
+		}
+		d.Allowed = true
+		d.Remaining = l.threshold - cnt - n
+		d.ResetAt = now.Add(l.window)
+	})
+	return d, nil
+}
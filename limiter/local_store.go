@@ -0,0 +1,68 @@
+package limiter
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const localShardCnt = 32
+
+// localStore 分段加锁的本地存储，不同的 key 尽量落在不同的分段上，减少锁竞争。
+// 超过 ttl 没有访问的 key 会在访问同一个分段的时候顺便清理掉
+type localStore[T any] struct {
+	shards [localShardCnt]*localShard[T]
+	ttl    time.Duration
+}
+
+type localShard[T any] struct {
+	lock      sync.Mutex
+	entries   map[string]*localEntry[T]
+	lastSweep time.Time
+}
+
+type localEntry[T any] struct {
+	val      T
+	expireAt time.Time
+}
+
+func newLocalStore[T any](ttl time.Duration) *localStore[T] {
+	s := &localStore[T]{ttl: ttl}
+	for i := range s.shards {
+		s.shards[i] = &localShard[T]{entries: make(map[string]*localEntry[T])}
+	}
+	return s
+}
+
+// do 在持有锁的情况下操作 key 对应的值，key 不存在的时候 fn 拿到的是零值
+func (s *localStore[T]) do(key string, now time.Time, fn func(val *T)) {
+	sd := s.shard(key)
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	if now.Sub(sd.lastSweep) >= s.ttl {
+		sd.sweep(now)
+	}
+	e, ok := sd.entries[key]
+	if !ok || now.After(e.expireAt) {
+		e = &localEntry[T]{}
+		sd.entries[key] = e
+	}
+	fn(&e.val)
+	e.expireAt = now.Add(s.ttl)
+}
+
+func (s *localStore[T]) shard(key string) *localShard[T] {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return s.shards[h.Sum32()%localShardCnt]
+}
+
+func (sd *localShard[T]) sweep(now time.Time) {
+	for key, e := range sd.entries {
+		if now.After(e.expireAt) {
+			delete(sd.entries, key)
+		}
+	}
+	sd.lastSweep = now
+}
@@ -0,0 +1,164 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LocalTokenBucketLimiter 本地令牌桶限流
+type LocalTokenBucketLimiter struct {
+	store    *localStore[tokenBucket]
+	capacity int64
+	// 每秒生成的令牌数
+	rate float64
+	now  func() time.Time
+
+	lock sync.Mutex
+	// id => 预留，过期的预留在 Reserve 的时候顺便清理，每隔 reservationSweep 清理一次
+	reservations map[string]Reservation
+	lastSweep    time.Time
+}
+
+const reservationSweep = time.Minute
+
+type tokenBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// NewLocalTokenBucketLimiter capacity 和 rate 必须是正数，rate 是 0 的话桶永远填不满，也就永远不会被淘汰
+func NewLocalTokenBucketLimiter(capacity int64, rate float64) *LocalTokenBucketLimiter {
+	if capacity <= 0 || !(rate > 0) {
+		panic(fmt.Sprintf("limiter: capacity %d and rate %v must be positive", capacity, rate))
+	}
+	return &LocalTokenBucketLimiter{
+		// 空闲到桶被填满之后，淘汰掉和新建一个桶是等价的
+		store:    newLocalStore[tokenBucket](time.Duration(float64(capacity) / rate * float64(time.Second))),
+		capacity: capacity,
+		rate:     rate,
+		now:      time.Now,
+
+		reservations: make(map[string]Reservation),
+	}
+}
+
+func (l *LocalTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return l.LimitN(ctx, key, 1)
+}
+
+func (l *LocalTokenBucketLimiter) LimitN(ctx context.Context, key string, n int64) (bool, error) {
+	d, err := l.DecideN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return !d.Allowed, nil
+}
+
+func (l *LocalTokenBucketLimiter) Decide(ctx context.Context, key string) (Decision, error) {
+	return l.DecideN(ctx, key, 1)
+}
+
+func (l *LocalTokenBucketLimiter) DecideN(ctx context.Context, key string, n int64) (Decision, error) {
+	if n <= 0 {
+		return Decision{}, ErrInvalidN
+	}
+	now := l.now()
+	d := Decision{Limit: l.capacity}
+	l.store.do(key, now, func(b *tokenBucket) {
+		capacity := float64(l.capacity)
+		if b.ts.IsZero() {
+			b.tokens = capacity
+			b.ts = now
+		}
+		//补充令牌
+		if now.After(b.ts) {
+			b.tokens = math.Min(capacity, b.tokens+now.Sub(b.ts).Seconds()*l.rate)
+			b.ts = now
+		}
+
+		if b.tokens >= float64(n) {
+			b.tokens -= float64(n)
+			d.Allowed = true
+		} else {
+			d.RetryAfter = l.duration(float64(n) - b.tokens)
+		}
+		d.Remaining = int64(b.tokens)
+		d.ResetAt = now.Add(l.duration(capacity - b.tokens))
+	})
+	return d, nil
+}
+
+// duration 生成 tokens 个令牌需要的时间
+func (l *LocalTokenBucketLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.rate * float64(time.Second)))
+}
+
+func (l *LocalTokenBucketLimiter) Reserve(ctx context.Context, key string, n int64, ttl time.Duration) (Reservation, error) {
+	d, err := l.DecideN(ctx, key, n)
+	if err != nil || !d.Allowed {
+		return Reservation{Decision: d}, err
+	}
+
+	now := l.now()
+	res := Reservation{
+		Decision: d,
+		Key:      key,
+		ID:       strconv.FormatUint(rand.Uint64(), 36),
+		Tokens:   n,
+		ExpireAt: now.Add(ttl),
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	// 没来得及清理的过期预留在 Refund 的时候也会按照 ExpireAt 判断
+	if now.Sub(l.lastSweep) >= reservationSweep {
+		for id, r := range l.reservations {
+			if now.After(r.ExpireAt) {
+				delete(l.reservations, id)
+			}
+		}
+		l.lastSweep = now
+	}
+	l.reservations[res.ID] = res
+	return res, nil
+}
+
+func (l *LocalTokenBucketLimiter) Cancel(ctx context.Context, res Reservation) error {
+	return l.Refund(ctx, res, res.Tokens)
+}
+
+func (l *LocalTokenBucketLimiter) Refund(ctx context.Context, res Reservation, n int64) error {
+	if n <= 0 {
+		return ErrInvalidN
+	}
+	now := l.now()
+	l.lock.Lock()
+	reserved, ok := l.reservations[res.ID]
+	delete(l.reservations, res.ID)
+	l.lock.Unlock()
+	if !ok || now.After(reserved.ExpireAt) {
+		return ErrReservationExpired
+	}
+
+	refund := float64(n)
+	if n > reserved.Tokens {
+		refund = float64(reserved.Tokens)
+	}
+	l.store.do(reserved.Key, now, func(b *tokenBucket) {
+		capacity := float64(l.capacity)
+		if b.ts.IsZero() {
+			b.tokens = capacity
+			b.ts = now
+		}
+		if now.After(b.ts) {
+			b.tokens += now.Sub(b.ts).Seconds() * l.rate
+			b.ts = now
+		}
+		b.tokens = math.Min(capacity, b.tokens+refund)
+	})
+	return nil
+}
@@ -0,0 +1,64 @@
+local now = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+--每次调用唯一的 id，避免同一毫秒的请求被合并成一个成员
+local id = ARGV[3]
+if n == nil or n <= 0 then
+    return redis.error_reply('n must be positive')
+end
+
+--每条规则对应一个 key，参数是 (窗口, 阈值)
+local cnts = {}
+local allowed = 1
+local remaining = -1
+local reset = now
+local retry = -1
+local rule = 1
+for i, key in ipairs(KEYS) do
+    local window = tonumber(ARGV[2 + 2 * i])
+    local threshold = tonumber(ARGV[3 + 2 * i])
+    redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+    local cnt = redis.call('ZCARD', key)
+    cnts[i] = cnt
+    if cnt + n > threshold then
+        allowed = 0
+        local wait = window
+        local need = redis.call('ZRANGE', key, cnt + n - threshold - 1, cnt + n - threshold - 1, 'WITHSCORES')
+        if #need > 0 then
+            wait = tonumber(need[2]) + window - now
+        end
+        --取需要等待最久的规则
+        if wait > retry then
+            retry = wait
+            rule = i
+            remaining = threshold - cnt
+            local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+            reset = now
+            if #newest > 0 then
+                reset = tonumber(newest[2]) + window
+            end
+        end
+    end
+end
+
+--返回 {是否放行, 剩余配额, 窗口清空的时间, 重试间隔, 生效的规则}
+if allowed == 0 then
+    return {0, remaining, reset, retry, rule - 1}
+end
+
+--所有规则都通过才记录请求，每个配额一个成员，一次最多写最小的 threshold 个成员
+for i, key in ipairs(KEYS) do
+    local window = tonumber(ARGV[2 + 2 * i])
+    local threshold = tonumber(ARGV[3 + 2 * i])
+    for j = 1, n do
+        redis.call('ZADD', key, now, id .. ':' .. j)
+    end
+    redis.call('PEXPIRE', key, window)
+    --剩余配额取最少的那条规则
+    local left = threshold - cnts[i] - n
+    if remaining < 0 or left < remaining then
+        remaining = left
+        rule = i
+        reset = now + window
+    end
+end
+return {1, remaining, reset, 0, rule - 1}
@@ -0,0 +1,216 @@
+package prometheus
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+	"test/webook/pkg/limiter"
+	"test/webook/pkg/prometheusx"
+	"time"
+)
+
+// Builder 给限流器加上监控，统计放行、拒绝、出错的次数和限流器的耗时（毫秒），按照 key 的前缀区分
+type Builder struct {
+	Namespace  string
+	Subsystem  string
+	Name       string
+	Help       string
+	InstanceId string
+	// 耗时的分桶，单位毫秒，为空的时候使用默认值
+	Buckets []float64
+	// 为空的时候注册到 prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+	// 从 key 里面提取前缀作为标签，为空的时候取第一个 ":" 之前的部分。
+	// 不能直接用 key 做标签，不然每个用户、每个 IP 都是一个时间序列
+	PrefixFn func(key string) string
+}
+
+// Build 返回的限流器和 l 实现同样的接口（limiter.DecisionLimiter、limiter.FeedbackLimiter、limiter.Reserver）
+func (b *Builder) Build(l limiter.Limiter) (limiter.Limiter, error) {
+	reg := b.Registerer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	buckets := b.Buckets
+	if len(buckets) == 0 {
+		buckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500}
+	}
+	prefixFn := b.PrefixFn
+	if prefixFn == nil {
+		prefixFn = defaultPrefix
+	}
+
+	counter, err := prometheusx.Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      b.Name + "_limit_total",
+		Help:      b.Help,
+		ConstLabels: map[string]string{
+			"instance_id": b.InstanceId,
+		},
+	}, []string{"prefix", "result"}))
+	if err != nil {
+		return nil, err
+	}
+	histogram, err := prometheusx.Register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      b.Name + "_limit_duration",
+		Help:      b.Help,
+		ConstLabels: map[string]string{
+			"instance_id": b.InstanceId,
+		},
+		Buckets: buckets,
+	}, []string{"prefix"}))
+	if err != nil {
+		return nil, err
+	}
+
+	base := &metricLimiter{
+		limiter:   l,
+		prefixFn:  prefixFn,
+		counter:   counter,
+		histogram: histogram,
+	}
+	var res limiter.Limiter = base
+	dl, isDecision := l.(limiter.DecisionLimiter)
+	fb, isFeedback := l.(limiter.FeedbackLimiter)
+	switch {
+	case isDecision && isFeedback:
+		res = &decisionFeedbackLimiter{
+			decisionLimiter: &decisionLimiter{metricLimiter: base, dl: dl},
+			fb:              fb,
+		}
+	case isDecision:
+		res = &decisionLimiter{metricLimiter: base, dl: dl}
+	case isFeedback:
+		res = &feedbackLimiter{metricLimiter: base, fb: fb}
+	}
+
+	rs, isReserver := l.(limiter.Reserver)
+	if !isReserver {
+		return res, nil
+	}
+	r := &reserver{metricLimiter: base, rs: rs}
+	switch res := res.(type) {
+	case *decisionFeedbackLimiter:
+		return &decisionFeedbackReserver{decisionFeedbackLimiter: res, reserver: r}, nil
+	case *decisionLimiter:
+		return &decisionReserver{decisionLimiter: res, reserver: r}, nil
+	case *feedbackLimiter:
+		return &feedbackReserver{feedbackLimiter: res, reserver: r}, nil
+	default:
+		return &reserverLimiter{metricLimiter: base, reserver: r}, nil
+	}
+}
+
+func defaultPrefix(key string) string {
+	prefix, _, _ := strings.Cut(key, ":")
+	return prefix
+}
+
+type metricLimiter struct {
+	limiter   limiter.Limiter
+	prefixFn  func(key string) string
+	counter   *prometheus.CounterVec
+	histogram *prometheus.HistogramVec
+}
+
+func (l *metricLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return l.LimitN(ctx, key, 1)
+}
+
+func (l *metricLimiter) LimitN(ctx context.Context, key string, n int64) (bool, error) {
+	start := time.Now()
+	limited, err := l.limiter.LimitN(ctx, key, n)
+	l.observe(key, start, !limited, err)
+	return limited, err
+}
+
+func (l *metricLimiter) observe(key string, start time.Time, allowed bool, err error) {
+	prefix := l.prefixFn(key)
+	l.histogram.WithLabelValues(prefix).Observe(float64(time.Since(start).Microseconds()) / 1000)
+	result := "allowed"
+	switch {
+	case err != nil:
+		result = "error"
+	case !allowed:
+		result = "denied"
+	}
+	l.counter.WithLabelValues(prefix, result).Inc()
+}
+
+type decisionLimiter struct {
+	*metricLimiter
+	dl limiter.DecisionLimiter
+}
+
+func (l *decisionLimiter) Decide(ctx context.Context, key string) (limiter.Decision, error) {
+	return l.DecideN(ctx, key, 1)
+}
+
+func (l *decisionLimiter) DecideN(ctx context.Context, key string, n int64) (limiter.Decision, error) {
+	start := time.Now()
+	d, err := l.dl.DecideN(ctx, key, n)
+	l.observe(key, start, d.Allowed, err)
+	return d, err
+}
+
+type feedbackLimiter struct {
+	*metricLimiter
+	fb limiter.FeedbackLimiter
+}
+
+func (l *feedbackLimiter) Done(ctx context.Context, key string, rt time.Duration) {
+	l.fb.Done(ctx, key, rt)
+}
+
+type decisionFeedbackLimiter struct {
+	*decisionLimiter
+	fb limiter.FeedbackLimiter
+}
+
+func (l *decisionFeedbackLimiter) Done(ctx context.Context, key string, rt time.Duration) {
+	l.fb.Done(ctx, key, rt)
+}
+
+// reserver 预留配额也要统计，Cancel 和 Refund 直接透传
+type reserver struct {
+	metricLimiter *metricLimiter
+	rs            limiter.Reserver
+}
+
+func (l *reserver) Reserve(ctx context.Context, key string, n int64, ttl time.Duration) (limiter.Reservation, error) {
+	start := time.Now()
+	r, err := l.rs.Reserve(ctx, key, n, ttl)
+	l.metricLimiter.observe(key, start, r.Allowed, err)
+	return r, err
+}
+
+func (l *reserver) Cancel(ctx context.Context, r limiter.Reservation) error {
+	return l.rs.Cancel(ctx, r)
+}
+
+func (l *reserver) Refund(ctx context.Context, r limiter.Reservation, n int64) error {
+	return l.rs.Refund(ctx, r, n)
+}
+
+type reserverLimiter struct {
+	*metricLimiter
+	*reserver
+}
+
+type decisionReserver struct {
+	*decisionLimiter
+	*reserver
+}
+
+type feedbackReserver struct {
+	*feedbackLimiter
+	*reserver
+}
+
+type decisionFeedbackReserver struct {
+	*decisionFeedbackLimiter
+	*reserver
+}
@@ -0,0 +1,78 @@
+package prometheus
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"test/webook/pkg/limiter"
+	"testing"
+	"time"
+)
+
+func TestBuilder_Interfaces(t *testing.T) {
+	testCases := []struct {
+		name string
+		l    limiter.Limiter
+
+		wantDecision bool
+		wantFeedback bool
+		wantReserver bool
+	}{
+		{
+			name:         "滑动窗口",
+			l:            limiter.NewLocalSlideWindowLimiter(time.Second, 10),
+			wantDecision: true,
+		},
+		{
+			name:         "令牌桶",
+			l:            limiter.NewLocalTokenBucketLimiter(10, 1),
+			wantDecision: true,
+			wantReserver: true,
+		},
+		{
+			name:         "BBR",
+			l:            limiter.NewBBRLimiter(time.Second, 10, 800),
+			wantFeedback: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &Builder{Name: "test", Registerer: prometheus.NewRegistry()}
+			l, err := b.Build(tc.l)
+			require.NoError(t, err)
+			_, ok := l.(limiter.DecisionLimiter)
+			assert.Equal(t, tc.wantDecision, ok)
+			_, ok = l.(limiter.FeedbackLimiter)
+			assert.Equal(t, tc.wantFeedback, ok)
+			_, ok = l.(limiter.Reserver)
+			assert.Equal(t, tc.wantReserver, ok)
+		})
+	}
+}
+
+func TestBuilder_Reserve(t *testing.T) {
+	b := &Builder{Name: "test", Registerer: prometheus.NewRegistry()}
+	l, err := b.Build(limiter.NewLocalTokenBucketLimiter(10, 1))
+	require.NoError(t, err)
+	rs := l.(limiter.Reserver)
+	ctx := context.Background()
+
+	res, err := rs.Reserve(ctx, "upload:123", 8, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	failed, err := rs.Reserve(ctx, "upload:123", 8, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, failed.Allowed)
+
+	require.NoError(t, rs.Cancel(ctx, res))
+	res, err = rs.Reserve(ctx, "upload:123", 8, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	counter := l.(*decisionReserver).counter
+	assert.Equal(t, float64(2), testutil.ToFloat64(counter.WithLabelValues("upload", "allowed")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues("upload", "denied")))
+}
@@ -0,0 +1,85 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//go:embed concurrency.lua
+var concurrencyLua string
+
+// RedisConcurrencyLimiter 分布式的并发限制，每个许可是一个带过期时间的租约，
+// 持有者宕机没有释放的话，租约到期之后自动回收。
+// lease 应该大于请求的最长处理时间，否则请求还没结束许可就被回收了
+type RedisConcurrencyLimiter struct {
+	cmd   redis.Cmdable
+	max   int64
+	lease time.Duration
+	// 排队时重试获取许可的间隔
+	interval time.Duration
+}
+
+// NewRedisConcurrencyLimiter max 和 lease 都必须是正数
+func NewRedisConcurrencyLimiter(client redis.Cmdable, max int64, lease time.Duration) *RedisConcurrencyLimiter {
+	if max <= 0 || lease <= 0 {
+		panic(fmt.Sprintf("limiter: max %d and lease %s must be positive", max, lease))
+	}
+	return &RedisConcurrencyLimiter{
+		cmd:      client,
+		max:      max,
+		lease:    lease,
+		interval: time.Millisecond * 10,
+	}
+}
+
+func (r *RedisConcurrencyLimiter) TryAcquire(ctx context.Context, key string) (func(), error) {
+	id := strconv.FormatUint(rand.Uint64(), 36)
+	ok, err := r.cmd.Eval(
+		ctx,
+		concurrencyLua,
+		[]string{key},
+		r.max, time.Now().UnixMilli(), r.lease.Milliseconds(), id,
+	).Bool()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrConcurrencyLimited
+	}
+	return r.releaseFunc(key, id), nil
+}
+
+func (r *RedisConcurrencyLimiter) Acquire(ctx context.Context, key string) (func(), error) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		release, err := r.TryAcquire(ctx, key)
+		if err != ErrConcurrencyLimited {
+			return release, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *RedisConcurrencyLimiter) releaseFunc(key, id string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// 请求的 ctx 可能已经取消了，这里单独设置超时。
+			// 释放失败也没关系，租约到期之后会自动回收
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			r.cmd.ZRem(ctx, key, id)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+//go:embed multi_rule.lua
+var multiRuleLua string
+
+// Rule 窗口 Window 内最多允许 Threshold 个请求
+type Rule struct {
+	Window    time.Duration
+	Threshold int64
+}
+
+// RedisMultiRuleLimiter 同一个 key 同时应用多条滑动窗口规则，例如每秒 10 次并且每小时 1000 次。
+// 所有规则在一次 lua 调用里面判断，只有全部通过才会记录请求。
+// 和 RedisSlideWindowLimiter 一样每个配额一个成员，DecideN 的开销和 n 成正比
+type RedisMultiRuleLimiter struct {
+	cmd   redis.Cmdable
+	rules []Rule
+}
+
+// NewRedisMultiRuleLimiter rules 不能为空，每条规则的 Window 和 Threshold 都必须是正数，否则 panic
+func NewRedisMultiRuleLimiter(client redis.Cmdable, rules ...Rule) *RedisMultiRuleLimiter {
+	if len(rules) == 0 {
+		panic("limiter: multi rule limiter needs at least one rule")
+	}
+	for i, rule := range rules {
+		if rule.Window <= 0 || rule.Threshold <= 0 {
+			panic(fmt.Sprintf("limiter: rule %d: window %s and threshold %d must be positive", i, rule.Window, rule.Threshold))
+		}
+	}
+	return &RedisMultiRuleLimiter{
+		cmd:   client,
+		rules: rules,
+	}
+}
+
+func (r *RedisMultiRuleLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return r.LimitN(ctx, key, 1)
+}
+
+func (r *RedisMultiRuleLimiter) LimitN(ctx context.Context, key string, n int64) (bool, error) {
+	d, err := r.DecideN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return !d.Allowed, nil
+}
+
+func (r *RedisMultiRuleLimiter) Decide(ctx context.Context, key string) (Decision, error) {
+	return r.DecideN(ctx, key, 1)
+}
+
+func (r *RedisMultiRuleLimiter) DecideN(ctx context.Context, key string, n int64) (Decision, error) {
+	if n <= 0 {
+		return Decision{}, ErrInvalidN
+	}
+	keys := make([]string, 0, len(r.rules))
+	args := make([]any, 0, 3+len(r.rules)*2)
+	args = append(args, time.Now().UnixMilli(), n, strconv.FormatUint(rand.Uint64(), 36))
+	for i, rule := range r.rules {
+		// 用 hash tag 保证所有规则的 key 落在同一个 slot 上。
+		// 用规则的下标区分，窗口一样、阈值不一样的规则也不会共用一个 key
+		keys = append(keys, fmt.Sprintf("%s:%d", hashTag(key), i))
+		args = append(args, rule.Window.Milliseconds(), rule.Threshold)
+	}
+
+	vals, err := r.cmd.Eval(ctx, multiRuleLua, keys, args...).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(vals) != 5 || vals[4] < 0 || vals[4] >= int64(len(r.rules)) {
+		return Decision{}, errInvalidResult
+	}
+	return newDecision(vals[:4], r.rules[vals[4]].Threshold)
+}
@@ -0,0 +1,59 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedisMultiRuleLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	// 窗口一样的两条规则，不能共用一个 key
+	l := NewRedisMultiRuleLimiter(client,
+		Rule{Window: time.Minute, Threshold: 4},
+		Rule{Window: time.Minute, Threshold: 10},
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		d, err := l.Decide(ctx, "a")
+		require.NoError(t, err)
+		require.True(t, d.Allowed)
+		assert.Equal(t, int64(3-i), d.Remaining)
+	}
+	d, err := l.Decide(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, int64(4), d.Limit)
+}
+
+func TestNewRedisMultiRuleLimiter(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules []Rule
+	}{
+		{
+			name: "没有规则",
+		},
+		{
+			name:  "窗口不是正数",
+			rules: []Rule{{Window: 0, Threshold: 1}},
+		},
+		{
+			name:  "阈值不是正数",
+			rules: []Rule{{Window: time.Second, Threshold: 1}, {Window: time.Minute, Threshold: 0}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Panics(t, func() {
+				NewRedisMultiRuleLimiter(nil, tc.rules...)
+			})
+		})
+	}
+}
@@ -4,12 +4,19 @@ import (
 	"context"
 	_ "embed"
 	"github.com/redis/go-redis/v9"
+	"math/rand"
+	"strconv"
 	"time"
 )
 
 //go:embed limit.lua
 var limitLua string
 
+// 优先使用 EVALSHA，Redis 返回 NOSCRIPT 的时候再用 EVAL 重新加载
+var limitScript = redis.NewScript(limitLua)
+
+// RedisSlideWindowLimiter 用 ZSET 记录窗口内的请求，每个配额一个成员，
+// 所以 DecideN 的开销和 n 成正比，最多写 threshold 个成员。threshold 很大又需要带权重的请求，用 RedisSlideWindowCounterLimiter
 type RedisSlideWindowLimiter struct {
 	cmd       redis.Cmdable
 	window    time.Duration
@@ -25,10 +32,34 @@ func NewRedisSlideWindowLimiter(client redis.Cmdable, window time.Duration, thre
 }
 
 func (r *RedisSlideWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
-	return r.cmd.Eval(
+	return r.LimitN(ctx, key, 1)
+}
+
+func (r *RedisSlideWindowLimiter) LimitN(ctx context.Context, key string, n int64) (bool, error) {
+	d, err := r.DecideN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return !d.Allowed, nil
+}
+
+func (r *RedisSlideWindowLimiter) Decide(ctx context.Context, key string) (Decision, error) {
+	return r.DecideN(ctx, key, 1)
+}
+
+func (r *RedisSlideWindowLimiter) DecideN(ctx context.Context, key string, n int64) (Decision, error) {
+	if n <= 0 {
+		return Decision{}, ErrInvalidN
+	}
+	vals, err := limitScript.Run(
 		ctx,
-		limitLua,
+		r.cmd,
 		[]string{key},
-		r.window.Milliseconds(), time.Now().UnixMilli(), r.threshold,
-	).Bool()
+		r.window.Milliseconds(), r.threshold, n,
+		strconv.FormatUint(rand.Uint64(), 36),
+	).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	return newDecision(vals, r.threshold)
 }
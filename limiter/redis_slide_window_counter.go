@@ -0,0 +1,60 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed slide_window_counter.lua
+var slideWindowCounterLua string
+
+// RedisSlideWindowCounterLimiter 滑动窗口计数器限流。
+// 每个 key 只保存当前和上一个固定窗口的计数，用上一个窗口的计数按时间加权估算滑动窗口内的请求数，
+// 内存占用是常数，代价是计数是近似值
+type RedisSlideWindowCounterLimiter struct {
+	cmd       redis.Cmdable
+	window    time.Duration
+	threshold int64
+}
+
+func NewRedisSlideWindowCounterLimiter(client redis.Cmdable, window time.Duration, threshold int64) *RedisSlideWindowCounterLimiter {
+	return &RedisSlideWindowCounterLimiter{
+		cmd:       client,
+		window:    window,
+		threshold: threshold,
+	}
+}
+
+func (r *RedisSlideWindowCounterLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return r.LimitN(ctx, key, 1)
+}
+
+func (r *RedisSlideWindowCounterLimiter) LimitN(ctx context.Context, key string, n int64) (bool, error) {
+	d, err := r.DecideN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return !d.Allowed, nil
+}
+
+func (r *RedisSlideWindowCounterLimiter) Decide(ctx context.Context, key string) (Decision, error) {
+	return r.DecideN(ctx, key, 1)
+}
+
+func (r *RedisSlideWindowCounterLimiter) DecideN(ctx context.Context, key string, n int64) (Decision, error) {
+	if n <= 0 {
+		return Decision{}, ErrInvalidN
+	}
+	vals, err := r.cmd.Eval(
+		ctx,
+		slideWindowCounterLua,
+		[]string{key},
+		r.window.Milliseconds(), time.Now().UnixMilli(), r.threshold, n,
+	).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	return newDecision(vals, r.threshold)
+}
@@ -0,0 +1,37 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedisSlideWindowLimiterN(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	l := NewRedisSlideWindowLimiter(client, time.Minute, 5)
+	ctx := context.Background()
+
+	// 超过 threshold 的请求永远不会放行，也不会写成员
+	d, err := l.DecideN(ctx, "a", 1000)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.False(t, mr.Exists("a"))
+
+	// key 保持原样，升级之前的窗口继续生效
+	d, err = l.DecideN(ctx, "a", 3)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, int64(2), d.Remaining)
+	members, err := mr.ZMembers("a")
+	require.NoError(t, err)
+	assert.Len(t, members, 3)
+
+	// 脚本里面兜底的校验
+	err = client.Eval(ctx, limitLua, []string{"a"}, 60000, 5, -1, "id").Err()
+	assert.EqualError(t, err, ErrInvalidN.Error())
+}
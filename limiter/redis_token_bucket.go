@@ -0,0 +1,128 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+var (
+	//go:embed token_bucket.lua
+	tokenBucketLua string
+	//go:embed token_bucket_refund.lua
+	tokenBucketRefundLua string
+)
+
+// RedisTokenBucketLimiter 令牌桶限流，允许 capacity 大小的突发流量，
+// 之后按 rate 的速率放行
+type RedisTokenBucketLimiter struct {
+	cmd      redis.Cmdable
+	capacity int64
+	// 每秒生成的令牌数
+	rate float64
+}
+
+// NewRedisTokenBucketLimiter capacity 和 rate 必须是正数，lua 脚本里面会用 rate 做除数
+func NewRedisTokenBucketLimiter(client redis.Cmdable, capacity int64, rate float64) *RedisTokenBucketLimiter {
+	if capacity <= 0 || !(rate > 0) {
+		panic(fmt.Sprintf("limiter: capacity %d and rate %v must be positive", capacity, rate))
+	}
+	return &RedisTokenBucketLimiter{
+		cmd:      client,
+		capacity: capacity,
+		rate:     rate,
+	}
+}
+
+func (r *RedisTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return r.LimitN(ctx, key, 1)
+}
+
+func (r *RedisTokenBucketLimiter) LimitN(ctx context.Context, key string, n int64) (bool, error) {
+	d, err := r.DecideN(ctx, key, n)
+	if err != nil {
+		return false, err
+	}
+	return !d.Allowed, nil
+}
+
+func (r *RedisTokenBucketLimiter) Decide(ctx context.Context, key string) (Decision, error) {
+	return r.DecideN(ctx, key, 1)
+}
+
+func (r *RedisTokenBucketLimiter) DecideN(ctx context.Context, key string, n int64) (Decision, error) {
+	if n <= 0 {
+		return Decision{}, ErrInvalidN
+	}
+	vals, err := r.cmd.Eval(
+		ctx,
+		tokenBucketLua,
+		[]string{key},
+		r.capacity, r.rate/1000, time.Now().UnixMilli(), n,
+	).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	return newDecision(vals, r.capacity)
+}
+
+func (r *RedisTokenBucketLimiter) Reserve(ctx context.Context, key string, n int64, ttl time.Duration) (Reservation, error) {
+	if n <= 0 {
+		return Reservation{}, ErrInvalidN
+	}
+	now := time.Now()
+	id := strconv.FormatUint(rand.Uint64(), 36)
+	vals, err := r.cmd.Eval(
+		ctx,
+		tokenBucketLua,
+		[]string{key, r.reservationKey(key, id)},
+		r.capacity, r.rate/1000, now.UnixMilli(), n, ttl.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return Reservation{}, err
+	}
+	d, err := newDecision(vals, r.capacity)
+	if err != nil || !d.Allowed {
+		return Reservation{Decision: d}, err
+	}
+	return Reservation{
+		Decision: d,
+		Key:      key,
+		ID:       id,
+		Tokens:   n,
+		ExpireAt: now.Add(ttl),
+	}, nil
+}
+
+func (r *RedisTokenBucketLimiter) Cancel(ctx context.Context, res Reservation) error {
+	return r.Refund(ctx, res, res.Tokens)
+}
+
+func (r *RedisTokenBucketLimiter) Refund(ctx context.Context, res Reservation, n int64) error {
+	if n <= 0 {
+		return ErrInvalidN
+	}
+	ok, err := r.cmd.Eval(
+		ctx,
+		tokenBucketRefundLua,
+		[]string{res.Key, r.reservationKey(res.Key, res.ID)},
+		r.capacity, r.rate/1000, time.Now().UnixMilli(), n,
+	).Bool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrReservationExpired
+	}
+	return nil
+}
+
+// reservationKey 令牌桶的 key 保持原样，预留的 key 加上 hash tag，
+// 这样两个 key 在 Redis Cluster 里面落在同一个 slot 上
+func (r *RedisTokenBucketLimiter) reservationKey(key, id string) string {
+	return hashTag(key) + ":reservation:" + id
+}
@@ -0,0 +1,35 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedisTokenBucketLimiterReserve(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	l := NewRedisTokenBucketLimiter(client, 10, 0.001)
+	ctx := context.Background()
+
+	res, err := l.Reserve(ctx, "a", 8, time.Minute)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	// 令牌桶的 key 保持原样
+	assert.True(t, mr.Exists("a"))
+
+	assert.Equal(t, ErrInvalidN, l.Refund(ctx, res, -5))
+	d, err := l.DecideN(ctx, "a", 3)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+
+	require.NoError(t, l.Refund(ctx, res, 5))
+	d, err = l.DecideN(ctx, "a", 7)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, ErrReservationExpired, l.Cancel(ctx, res))
+}
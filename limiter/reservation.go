@@ -0,0 +1,30 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrReservationExpired = errors.New("reservation expired or already canceled")
+
+// Reserver 长时间运行的操作可以先预留配额，操作取消的时候再归还。
+// 目前只有令牌桶（LocalTokenBucketLimiter、RedisTokenBucketLimiter）实现了 Reserver，
+// 滑动窗口按照请求的时间统计，归还的配额应该从窗口里面删掉哪些请求没有明确的语义，所以不支持
+type Reserver interface {
+	// Reserve 预留 n 个配额，Allowed 为 false 表示配额不足，没有预留成功。
+	// 预留在 ttl 之后过期，过期之后就不能再归还了
+	Reserve(ctx context.Context, key string, n int64, ttl time.Duration) (Reservation, error)
+	// Cancel 取消预留，归还全部配额
+	Cancel(ctx context.Context, r Reservation) error
+	// Refund 归还 n 个没有用掉的配额，之后这个预留就结束了
+	Refund(ctx context.Context, r Reservation, n int64) error
+}
+
+type Reservation struct {
+	Decision
+	Key      string
+	ID       string
+	Tokens   int64
+	ExpireAt time.Time
+}
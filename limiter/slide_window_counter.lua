@@ -0,0 +1,42 @@
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+local threshold = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+if n == nil or n <= 0 then
+    return redis.error_reply('n must be positive')
+end
+
+--当前固定窗口的编号
+local idx = math.floor(now / window)
+local state = redis.call('HMGET', key, 'idx', 'cur', 'prev')
+local last = tonumber(state[1])
+local cur = tonumber(state[2]) or 0
+local prev = tonumber(state[3]) or 0
+if last == nil or last < idx - 1 then
+    cur = 0
+    prev = 0
+elseif last == idx - 1 then
+    prev = cur
+    cur = 0
+end
+
+--上一个窗口按照它还留在滑动窗口里的比例计数
+local elapsed = now - idx * window
+local cnt = prev * (window - elapsed) / window + cur
+
+--返回 {是否放行, 剩余配额, 计数清零的时间, 重试间隔}
+local reset = (idx + 2) * window
+if cnt + n > threshold then
+    --上一个窗口的计数随时间衰减，算出衰减到足够放行的时间，
+    --只靠衰减不够的话就要等到下一个窗口
+    local retry = window - elapsed
+    if prev > 0 and cur + n <= threshold then
+        retry = math.ceil(window - (threshold - cur - n) * window / prev - elapsed)
+    end
+    return {0, math.max(0, math.floor(threshold - cnt)), reset, retry}
+end
+
+redis.call('HSET', key, 'idx', idx, 'cur', cur + n, 'prev', prev)
+redis.call('PEXPIRE', key, window * 2)
+return {1, math.floor(threshold - cnt - n), reset, 0}
@@ -0,0 +1,46 @@
+local key = KEYS[1]
+-- 桶容量
+local capacity = tonumber(ARGV[1])
+-- 每毫秒生成的令牌数
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+if n == nil or n <= 0 then
+    return redis.error_reply('n must be positive')
+end
+--预留配额的时候才有，预留的 key 和过期时间
+local reservation = KEYS[2]
+local reservationTTL = tonumber(ARGV[5])
+
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+    tokens = capacity
+    ts = now
+end
+
+--补充令牌
+if now > ts then
+    tokens = math.min(capacity, tokens + (now - ts) * rate)
+    ts = now
+end
+
+local allowed = 0
+local retry = 0
+if tokens >= n then
+    allowed = 1
+    tokens = tokens - n
+    if reservation then
+        redis.call('SET', reservation, n, 'PX', reservationTTL)
+    end
+else
+    retry = math.ceil((n - tokens) / rate)
+end
+
+redis.call('HSET', key, 'tokens', tokens, 'ts', ts)
+--桶填满所需的时间之后自动过期
+redis.call('PEXPIRE', key, math.ceil(capacity / rate))
+
+--返回 {是否放行, 剩余令牌, 桶填满的时间, 重试间隔}
+return {allowed, math.floor(tokens), now + math.ceil((capacity - tokens) / rate), retry}
@@ -0,0 +1,33 @@
+local key = KEYS[1]
+local reservation = KEYS[2]
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local refund = tonumber(ARGV[4])
+if refund == nil or refund <= 0 then
+    return redis.error_reply('n must be positive')
+end
+
+--预留已经过期或者已经归还过了
+local reserved = tonumber(redis.call('GET', reservation))
+if reserved == nil then
+    return 0
+end
+redis.call('DEL', reservation)
+
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+    tokens = capacity
+    ts = now
+end
+if now > ts then
+    tokens = tokens + (now - ts) * rate
+    ts = now
+end
+tokens = math.min(capacity, tokens + math.min(refund, reserved))
+
+redis.call('HSET', key, 'tokens', tokens, 'ts', ts)
+redis.call('PEXPIRE', key, math.ceil(capacity / rate))
+return 1
@@ -1,7 +1,64 @@
 package limiter
 
-import "context"
+import (
+	"context"
+	"strings"
+	"time"
+)
 
 type Limiter interface {
 	Limit(ctx context.Context, key string) (bool, error)
+	// LimitN 一次消耗 n 个配额，开销大的请求可以消耗更多的配额
+	LimitN(ctx context.Context, key string, n int64) (bool, error)
+}
+
+// DecisionLimiter 除了是否限流，还能给出剩余配额和重试时间
+type DecisionLimiter interface {
+	Limiter
+	Decide(ctx context.Context, key string) (Decision, error)
+	DecideN(ctx context.Context, key string, n int64) (Decision, error)
+}
+
+// FeedbackLimiter 放行的请求处理完之后需要调用 Done 反馈处理时间，
+// 例如根据响应时间自适应的 BBRLimiter
+type FeedbackLimiter interface {
+	Limiter
+	Done(ctx context.Context, key string, rt time.Duration)
+}
+
+type Decision struct {
+	Allowed bool
+	// 阈值
+	Limit int64
+	// 剩余配额
+	Remaining int64
+	// 配额完全恢复的时间
+	ResetAt time.Time
+	// 被限流时建议的重试间隔
+	RetryAfter time.Duration
+}
+
+// newDecision 解析 lua 脚本返回的 {allowed, remaining, reset(毫秒时间戳), retry_after(毫秒)}
+func newDecision(vals []int64, limit int64) (Decision, error) {
+	if len(vals) != 4 {
+		return Decision{}, errInvalidResult
+	}
+	return Decision{
+		Allowed:    vals[0] == 1,
+		Limit:      limit,
+		Remaining:  vals[1],
+		ResetAt:    time.UnixMilli(vals[2]),
+		RetryAfter: time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
+
+// hashTag 给 key 加上 Redis Cluster 的 hash tag，保证同一个 key 衍生出来的 key 落在同一个 slot 上。
+// key 里面已经有 hash tag 的话保持不变。
+// 只有一个 key 的脚本不要用，加上 hash tag 会改变 key，升级之后线上已有的窗口和令牌桶都会被重置
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start >= 0 && strings.IndexByte(key[start+1:], '}') > 0 {
+		return key
+	}
+	return "{" + key + "}"
 }
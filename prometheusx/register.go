@@ -0,0 +1,28 @@
+package prometheusx
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Register 注册 c，同名的指标已经注册过的话直接复用之前的 collector，这样同一个 Builder 可以 Build 多次。
+// 已经注册的 collector 类型和 c 不一样的时候返回 prometheus.AlreadyRegisteredError
+func Register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	err := reg.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	return c, err
+}
+
+// MustRegister 和 Register 一样，出错的时候 panic
+func MustRegister[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	res, err := Register(reg, c)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
@@ -0,0 +1,28 @@
+package prometheusx
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	opts := prometheus.CounterOpts{Name: "test_total"}
+
+	first, err := Register(reg, prometheus.NewCounterVec(opts, []string{"code"}))
+	require.NoError(t, err)
+	// 同名的指标复用之前注册的
+	second, err := Register(reg, prometheus.NewCounterVec(opts, []string{"code"}))
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	// 描述一样但是类型不一样的时候不能复用
+	_, err = Register(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_total"}, []string{"code"}))
+	var are prometheus.AlreadyRegisteredError
+	assert.ErrorAs(t, err, &are)
+	assert.Panics(t, func() {
+		MustRegister(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_total"}, []string{"code"}))
+	})
+}
@@ -4,13 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"test/webook/pkg/logger"
 	"time"
 )
 
 type BatchHandler[T any] struct {
-	fn func(msg []*sarama.ConsumerMessage, evt []T) error
+	fn func(ctx context.Context, msg []*sarama.ConsumerMessage, evt []T) error
 	l  logger.Logger
+	tracing
+}
+
+// TracerProvider 默认使用全局的 TracerProvider
+func (b *BatchHandler[T]) TracerProvider(tp trace.TracerProvider) *BatchHandler[T] {
+	b.tracer = tp.Tracer(instrumentationName)
+	return b
+}
+
+// Propagator 默认使用全局的 propagator，要和生产者一致
+func (b *BatchHandler[T]) Propagator(propagator propagation.TextMapPropagator) *BatchHandler[T] {
+	b.propagator = propagator
+	return b
 }
 
 func (b *BatchHandler[T]) Setup(session sarama.ConsumerGroupSession) error {
@@ -56,7 +71,9 @@ func (b *BatchHandler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 			continue
 		}
 
-		err := b.fn(messages, evts)
+		spanCtx, span := b.startBatchConsume(messages)
+		err := b.fn(spanCtx, messages, evts)
+		endSpan(span, err)
 		if err != nil {
 			b.l.Error("消费失败",
 				logger.Error(err),
@@ -70,5 +87,12 @@ func (b *BatchHandler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 }
 
 func NewBatchHandler[T any](fn func(msg []*sarama.ConsumerMessage, evt []T) error, l logger.Logger) *BatchHandler[T] {
-	return &BatchHandler[T]{l: l, fn: fn}
+	return NewContextBatchHandler[T](func(ctx context.Context, msg []*sarama.ConsumerMessage, evt []T) error {
+		return fn(msg, evt)
+	}, l)
+}
+
+// NewContextBatchHandler 和 NewBatchHandler 一样，fn 拿到的 ctx 带着这一批消息的 span
+func NewContextBatchHandler[T any](fn func(ctx context.Context, msg []*sarama.ConsumerMessage, evt []T) error, l logger.Logger) *BatchHandler[T] {
+	return &BatchHandler[T]{l: l, fn: fn, tracing: newTracing()}
 }
@@ -1,18 +1,42 @@
 package saramax
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"test/webook/pkg/logger"
 )
 
 type Handler[T any] struct {
-	fn func(msg *sarama.ConsumerMessage, evt T) error
+	fn func(ctx context.Context, msg *sarama.ConsumerMessage, evt T) error
 	l  logger.Logger
+	tracing
 }
 
 func NewHandler[T any](fn func(msg *sarama.ConsumerMessage, evt T) error, l logger.Logger) *Handler[T] {
-	return &Handler[T]{l: l, fn: fn}
+	return NewContextHandler[T](func(ctx context.Context, msg *sarama.ConsumerMessage, evt T) error {
+		return fn(msg, evt)
+	}, l)
+}
+
+// NewContextHandler 和 NewHandler 一样，只是 fn 能拿到带有 trace 的 ctx，
+// 用这个 ctx 调用下游，下游的 span 就能接上生产者的 trace
+func NewContextHandler[T any](fn func(ctx context.Context, msg *sarama.ConsumerMessage, evt T) error, l logger.Logger) *Handler[T] {
+	return &Handler[T]{l: l, fn: fn, tracing: newTracing()}
+}
+
+// TracerProvider 默认使用全局的 TracerProvider
+func (h *Handler[T]) TracerProvider(tp trace.TracerProvider) *Handler[T] {
+	h.tracer = tp.Tracer(instrumentationName)
+	return h
+}
+
+// Propagator 默认使用全局的 propagator，要和生产者一致
+func (h *Handler[T]) Propagator(propagator propagation.TextMapPropagator) *Handler[T] {
+	h.propagator = propagator
+	return h
 }
 
 func (h *Handler[T]) Setup(session sarama.ConsumerGroupSession) error {
@@ -27,6 +51,7 @@ func (h *Handler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sar
 	messages := claim.Messages()
 
 	for msg := range messages {
+		ctx, span := h.startConsume(msg)
 
 		var t T
 		err := json.Unmarshal(msg.Value, &t)
@@ -39,7 +64,8 @@ func (h *Handler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sar
 			)
 		}
 
-		err = h.fn(msg, t)
+		err = h.fn(ctx, msg, t)
+		endSpan(span, err)
 		if err != nil {
 			h.l.Error("消费失败",
 				logger.Any[string]("topic", msg.Topic),
@@ -0,0 +1,181 @@
+package saramax
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
+)
+
+const instrumentationName = "test/webook/pkg/saramax"
+
+// SyncProducer 发送消息的时候创建一个 span，并且把 trace 放进消息头里面，
+// 消费者用 Handler 或者 BatchHandler 消费的时候就能接上这条 trace
+type SyncProducer struct {
+	sarama.SyncProducer
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// NewSyncProducer 使用全局的 TracerProvider 和 propagator
+func NewSyncProducer(p sarama.SyncProducer) *SyncProducer {
+	return &SyncProducer{
+		SyncProducer: p,
+		tracer:       otel.GetTracerProvider().Tracer(instrumentationName),
+		propagator:   otel.GetTextMapPropagator(),
+	}
+}
+
+func (p *SyncProducer) TracerProvider(tp trace.TracerProvider) *SyncProducer {
+	p.tracer = tp.Tracer(instrumentationName)
+	return p
+}
+
+func (p *SyncProducer) Propagator(propagator propagation.TextMapPropagator) *SyncProducer {
+	p.propagator = propagator
+	return p
+}
+
+// SendMessageContext 和 SendMessage 一样，只是 span 挂在 ctx 下面
+func (p *SyncProducer) SendMessageContext(ctx context.Context, msg *sarama.ProducerMessage) (int32, int64, error) {
+	ctx, span := p.tracer.Start(ctx, msg.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", msg.Topic),
+		))
+	defer span.End()
+
+	p.propagator.Inject(ctx, producerCarrier{msg: msg})
+	partition, offset, err := p.SendMessage(msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return partition, offset, err
+	}
+	span.SetAttributes(
+		attribute.String("messaging.destination.partition.id", strconv.FormatInt(int64(partition), 10)),
+		attribute.Int64("messaging.kafka.offset", offset),
+	)
+	return partition, offset, nil
+}
+
+// tracing 是 Handler 和 BatchHandler 共用的部分
+type tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+func newTracing() tracing {
+	return tracing{
+		tracer:     otel.GetTracerProvider().Tracer(instrumentationName),
+		propagator: otel.GetTextMapPropagator(),
+	}
+}
+
+// startConsume 一条消息一个 span，父 span 是生产者的 span
+func (t tracing) startConsume(msg *sarama.ConsumerMessage) (context.Context, trace.Span) {
+	ctx := t.propagator.Extract(context.Background(), consumerCarrier(msg.Headers))
+	return t.tracer.Start(ctx, msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(consumeAttrs(msg)...),
+	)
+}
+
+// startBatchConsume 一批消息一个 span，消息来自不同的 trace，所以用 link 关联生产者的 span
+func (t tracing) startBatchConsume(msgs []*sarama.ConsumerMessage) (context.Context, trace.Span) {
+	links := make([]trace.Link, 0, len(msgs))
+	for _, msg := range msgs {
+		ctx := t.propagator.Extract(context.Background(), consumerCarrier(msg.Headers))
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+	return t.tracer.Start(context.Background(), msgs[0].Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", msgs[0].Topic),
+			attribute.Int("messaging.batch.message_count", len(msgs)),
+		),
+	)
+}
+
+func consumeAttrs(msg *sarama.ConsumerMessage) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", msg.Topic),
+		attribute.String("messaging.destination.partition.id", strconv.FormatInt(int64(msg.Partition), 10)),
+		attribute.Int64("messaging.kafka.offset", msg.Offset),
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// producerCarrier 让 propagator 能读写生产者消息的头部
+type producerCarrier struct {
+	msg *sarama.ProducerMessage
+}
+
+func (c producerCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c producerCarrier) Set(key, value string) {
+	// 重试发送的时候会再注入一次，覆盖掉旧的值
+	for i, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c producerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		keys = append(keys, string(h.Key))
+	}
+	return keys
+}
+
+// consumerCarrier 消费者这边只需要读
+type consumerCarrier []*sarama.RecordHeader
+
+func (c consumerCarrier) Get(key string) string {
+	for _, h := range c {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c consumerCarrier) Set(key, value string) {}
+
+func (c consumerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for _, h := range c {
+		if h != nil {
+			keys = append(keys, string(h.Key))
+		}
+	}
+	return keys
+}
@@ -0,0 +1,148 @@
+package saramax
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"test/webook/pkg/logger"
+	"testing"
+)
+
+type Event struct {
+	Id int64 `json:"id"`
+}
+
+func TestHandler_Trace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	propagator := propagation.TraceContext{}
+
+	msg, producerSpan := produce(t, tp, propagator, `{"id":1}`)
+
+	var handlerSpan trace.SpanContext
+	h := NewContextHandler[Event](func(ctx context.Context, msg *sarama.ConsumerMessage, evt Event) error {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+		assert.Equal(t, Event{Id: 1}, evt)
+		return errors.New("mock error")
+	}, logger.NewNoLogger()).TracerProvider(tp).Propagator(propagator)
+
+	exporter.Reset()
+	session := &mockSession{}
+	require.NoError(t, h.ConsumeClaim(session, newMockClaim(msg)))
+	assert.Len(t, session.marked, 1)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "test_topic process", span.Name)
+	assert.Equal(t, trace.SpanKindConsumer, span.SpanKind)
+	// 接上生产者的 trace
+	assert.Equal(t, producerSpan.TraceID(), span.SpanContext.TraceID())
+	assert.Equal(t, producerSpan.SpanID(), span.Parent.SpanID())
+	assert.Equal(t, span.SpanContext.SpanID(), handlerSpan.SpanID())
+	assert.Equal(t, codes.Error, span.Status.Code)
+}
+
+func TestBatchHandler_Trace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	propagator := propagation.TraceContext{}
+
+	// 凑满一批，不用等超时
+	msgs := make([]*sarama.ConsumerMessage, 0, 10)
+	producerSpans := make([]trace.SpanContext, 0, 9)
+	for i := 0; i < 9; i++ {
+		msg, sc := produce(t, tp, propagator, `{"id":1}`)
+		msgs = append(msgs, msg)
+		producerSpans = append(producerSpans, sc)
+	}
+	// 没有 trace 的消息不关联
+	msgs = append(msgs, &sarama.ConsumerMessage{Topic: "test_topic", Value: []byte(`{"id":2}`)})
+
+	var handlerSpan trace.SpanContext
+	h := NewContextBatchHandler[Event](func(ctx context.Context, msgs []*sarama.ConsumerMessage, evts []Event) error {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+		assert.Len(t, evts, 10)
+		return nil
+	}, logger.NewNoLogger()).TracerProvider(tp).Propagator(propagator)
+
+	exporter.Reset()
+	session := &mockSession{}
+	require.NoError(t, h.ConsumeClaim(session, newMockClaim(msgs...)))
+	assert.Len(t, session.marked, 10)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "test_topic process", span.Name)
+	assert.Equal(t, trace.SpanKindConsumer, span.SpanKind)
+	// 一批消息来自不同的 trace，不设置父 span，用 link 关联
+	assert.False(t, span.Parent.IsValid())
+	assert.Equal(t, span.SpanContext.SpanID(), handlerSpan.SpanID())
+	require.Len(t, span.Links, len(producerSpans))
+	for i, link := range span.Links {
+		assert.Equal(t, producerSpans[i].TraceID(), link.SpanContext.TraceID())
+		assert.Equal(t, producerSpans[i].SpanID(), link.SpanContext.SpanID())
+	}
+	assert.Equal(t, codes.Unset, span.Status.Code)
+}
+
+// produce 通过 SyncProducer 发送一条消息，返回消费者收到的消息和生产者的 span
+func produce(t *testing.T, tp trace.TracerProvider,
+	propagator propagation.TextMapPropagator, val string) (*sarama.ConsumerMessage, trace.SpanContext) {
+	mock := mocks.NewSyncProducer(t, nil)
+	mock.ExpectSendMessageAndSucceed()
+	p := NewSyncProducer(mock).TracerProvider(tp).Propagator(propagator)
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "parent")
+	defer span.End()
+	msg := &sarama.ProducerMessage{Topic: "test_topic", Value: sarama.StringEncoder(val)}
+	_, _, err := p.SendMessageContext(ctx, msg)
+	require.NoError(t, err)
+
+	headers := make([]*sarama.RecordHeader, 0, len(msg.Headers))
+	for i := range msg.Headers {
+		headers = append(headers, &msg.Headers[i])
+	}
+	// 消费者接上的是 publish 的 span，不是调用方的 span
+	producerSpan := trace.SpanContextFromContext(propagator.Extract(context.Background(), consumerCarrier(headers)))
+	require.True(t, producerSpan.IsValid())
+	require.NotEqual(t, span.SpanContext().SpanID(), producerSpan.SpanID())
+	return &sarama.ConsumerMessage{Topic: msg.Topic, Value: []byte(val), Headers: headers}, producerSpan
+}
+
+type mockSession struct {
+	sarama.ConsumerGroupSession
+	marked []*sarama.ConsumerMessage
+}
+
+func (s *mockSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg)
+}
+
+type mockClaim struct {
+	sarama.ConsumerGroupClaim
+	msgs chan *sarama.ConsumerMessage
+}
+
+// newMockClaim msgs 消费完之后关闭
+func newMockClaim(msgs ...*sarama.ConsumerMessage) *mockClaim {
+	ch := make(chan *sarama.ConsumerMessage, len(msgs))
+	for _, msg := range msgs {
+		ch <- msg
+	}
+	close(ch)
+	return &mockClaim{msgs: ch}
+}
+
+func (c *mockClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.msgs
+}